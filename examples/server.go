@@ -87,7 +87,11 @@ func homeHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func postHandler(w http.ResponseWriter, r *http.Request) {
-	bytes, _ := ioutil.ReadAll(r.Body)
+	bytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
 
 	if _, err := w.Write([]byte(fmt.Sprintf("response: %v\n", string(bytes)))); err != nil {
 		w.WriteHeader(500)
@@ -100,6 +104,7 @@ func postHandler(w http.ResponseWriter, r *http.Request) {
 func main() {
 	fw := framework.New()
 	fw = fw.WithNotFoundHandler(http.NotFoundHandler())
+	fw = fw.WithMaxBodySize(1 << 20)
 
 	fw.Get("/", http.HandlerFunc(homeHandler))
 	fw.Get("/test3", http.HandlerFunc(homeHandler))
@@ -117,7 +122,7 @@ func main() {
 		fw.Get("/hello/:fname/:lname/", http.HandlerFunc(helloHandler))
 		fw.Get("/hello/:fname/*", http.HandlerFunc(helloSplatHandler))
 		fw.Get("/*", http.HandlerFunc(fallbackHandler))
-		fw.Post("/post/*", http.HandlerFunc(postHandler))
+		fw.Post("/post/*", http.HandlerFunc(postHandler), framework.MaxBodySize(8<<20))
 	})
 
 	fw.Attach(logMiddleware)
@@ -0,0 +1,159 @@
+package framework
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+type principalKey struct{}
+
+var (
+	// ErrUnauthenticated is returned by a Policy if the request has no authenticated principal.
+	ErrUnauthenticated = errors.New("authentication required")
+
+	// ErrForbidden is returned by a Policy if the principal lacks the required permissions.
+	ErrForbidden = errors.New("insufficient permissions")
+)
+
+// Policy decides if the request is allowed to access a route with the required permissions.
+// ErrUnauthenticated results in HTTP 401 and any other error in HTTP 403.
+type Policy interface {
+	Authorize(r *http.Request, required []string) error
+}
+
+// PolicyFunc is an adapter to use ordinary functions as a Policy.
+type PolicyFunc func(r *http.Request, required []string) error
+
+// Authorize implements the Policy interface.
+func (f PolicyFunc) Authorize(r *http.Request, required []string) error {
+	return f(r, required)
+}
+
+// Principal is the authenticated principal stored in the request context by an
+// authentication middleware, e.g. auth.Identity.
+type Principal interface {
+	// Permissions returns the roles and scopes granted to the principal.
+	Permissions() []string
+}
+
+// WithPrincipal returns a copy of the context with the principal stored.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// GetPrincipal gets the authenticated principal from the context.
+func GetPrincipal(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok && p != nil
+}
+
+// Challenger provides a WWW-Authenticate challenge, e.g. auth.Authenticator.
+type Challenger interface {
+	Challenge() string
+}
+
+// defaultChallenge is sent with HTTP 401 if there are no other challenges as RFC 9110
+// requires at least one.
+const defaultChallenge = "Bearer"
+
+// PrincipalPolicy is the default Policy. It requires the Principal stored in the request
+// context, e.g. by the auth middleware, to have all the required permissions.
+var PrincipalPolicy = PolicyFunc(func(r *http.Request, required []string) error {
+	p, ok := GetPrincipal(r.Context())
+	if !ok {
+		return ErrUnauthenticated
+	}
+
+	perms := p.Permissions()
+
+	granted := make(map[string]bool, len(perms))
+	for _, perm := range perms {
+		granted[perm] = true
+	}
+
+	for _, perm := range required {
+		if !granted[perm] {
+			return ErrForbidden
+		}
+	}
+
+	return nil
+})
+
+// Require declares the roles or scopes required to access a route or a group of routes. The
+// group and the route requirements are combined. The requirements are checked with the
+// Framework Policy after the route middlewares, so the authentication middleware can be either
+// attached globally or with Use.
+func Require(permissions ...string) RouteOption {
+	return func(cfg *routeConfig) {
+		cfg.permissions = append(cfg.permissions, permissions...)
+	}
+}
+
+// WithPolicy sets the Policy used to check the route requirements. PrincipalPolicy is used by
+// default.
+func (fw *Framework) WithPolicy(policy Policy) *Framework {
+	fw.configure(func(s *settings) {
+		s.policy = policy
+	})
+
+	return fw
+}
+
+// WithChallenge sets the WWW-Authenticate challenges of the HTTP 401 responses of the routes
+// requiring permissions, e.g. the authenticators of the auth middleware. The "Bearer" challenge
+// is sent by default.
+func (fw *Framework) WithChallenge(challengers ...Challenger) *Framework {
+	fw.configure(func(s *settings) {
+		// the published slice is shared with the requests in flight
+		s.challengers = append(append([]Challenger{}, s.challengers...), challengers...)
+	})
+
+	return fw
+}
+
+// unauthorized responds with HTTP 401 and the WWW-Authenticate challenges.
+func unauthorized(w http.ResponseWriter, challengers []Challenger, err error) {
+	for _, c := range challengers {
+		if challenge := c.Challenge(); challenge != "" {
+			w.Header().Add("WWW-Authenticate", challenge)
+		}
+	}
+
+	if w.Header().Get("WWW-Authenticate") == "" {
+		w.Header().Set("WWW-Authenticate", defaultChallenge)
+	}
+
+	returnError(w, err.Error(), http.StatusUnauthorized)
+}
+
+// authorize checks the route requirements before calling the handler.
+func (fw *Framework) authorize(cfg *routeConfig, next http.Handler) http.Handler {
+	if len(cfg.permissions) == 0 {
+		return next
+	}
+
+	required := append([]string{}, cfg.permissions...)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := fw.settings()
+
+		policy := s.policy
+		if policy == nil {
+			policy = PrincipalPolicy
+		}
+
+		if err := policy.Authorize(r, required); err != nil {
+			if errors.Is(err, ErrUnauthenticated) {
+				unauthorized(w, s.challengers, err)
+			} else {
+				returnError(w, err.Error(), http.StatusForbidden)
+			}
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,186 @@
+package framework_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/framework"
+	"github.com/snobb/susanin/pkg/middleware/auth"
+	"github.com/snobb/susanin/test/helper"
+)
+
+func TestFramework_Require(t *testing.T) {
+	authn := auth.New(auth.NewAPIKey(auth.APIKeyConfig{
+		Keys: map[string]*auth.Identity{
+			"reader": {Subject: "reader", Scopes: []string{"users:read"}},
+			"writer": {Subject: "writer", Scopes: []string{"users:read", "users:write"}},
+			"admin":  {Subject: "admin", Roles: []string{"admin"}, Scopes: []string{"users:read"}},
+		},
+	}))
+
+	tests := map[string]struct {
+		method   string
+		path     string
+		apiKey   string
+		wantCode int
+		wantBody string
+	}{
+		"should allow public route without credentials": {
+			method:   http.MethodGet,
+			path:     "/public",
+			wantCode: 200,
+			wantBody: "public",
+		},
+		"should allow route with the required scope": {
+			method:   http.MethodGet,
+			path:     "/users/1",
+			apiKey:   "reader",
+			wantCode: 200,
+			wantBody: "get",
+		},
+		"should forbid route without the required scope": {
+			method:   http.MethodDelete,
+			path:     "/users/1",
+			apiKey:   "reader",
+			wantCode: 403,
+			wantBody: `{"code":403,"msg":"insufficient permissions"}`,
+		},
+		"should allow route with the required scope for writers": {
+			method:   http.MethodDelete,
+			path:     "/users/1",
+			apiKey:   "writer",
+			wantCode: 200,
+			wantBody: "delete",
+		},
+		"should combine the group and route requirements": {
+			method:   http.MethodDelete,
+			path:     "/admin/users/1",
+			apiKey:   "writer",
+			wantCode: 403,
+			wantBody: `{"code":403,"msg":"insufficient permissions"}`,
+		},
+		"should allow admin route with the role and the scope": {
+			method:   http.MethodGet,
+			path:     "/admin/users/1",
+			apiKey:   "admin",
+			wantCode: 200,
+			wantBody: "admin",
+		},
+		"should respond 401 if the group authentication has not run": {
+			method:   http.MethodGet,
+			path:     "/open/users/1",
+			wantCode: 401,
+			wantBody: `{"code":401,"msg":"authentication required"}`,
+		},
+	}
+
+	fw := framework.New()
+	fw.Get("/public", helper.HandlerFactory(200, "public"))
+	fw.Get("/open/users/:id", helper.HandlerFactory(200, "open"), framework.Require("users:read"))
+	fw.WithPrefix("/", func() {
+		fw.Get("/users/:id", helper.HandlerFactory(200, "get"), framework.Require("users:read"))
+		fw.Delete("/users/:id", helper.HandlerFactory(200, "delete"),
+			framework.Require("users:write"))
+
+		fw.WithPrefix("/admin", func() {
+			fw.Get("/users/:id", helper.HandlerFactory(200, "admin"), framework.Require("users:read"))
+			fw.Delete("/users/:id", helper.HandlerFactory(200, "admin"),
+				framework.Require("users:write"))
+		}, framework.Require("admin"))
+	}, framework.Use(authn))
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+
+			fw.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+			assert.Equal(t, tt.wantBody, strings.TrimSpace(rr.Body.String()))
+		})
+	}
+}
+
+func TestFramework_WithPolicy(t *testing.T) {
+	fw := framework.New().WithPolicy(framework.PolicyFunc(
+		func(r *http.Request, required []string) error {
+			if r.Header.Get("X-Grant") != strings.Join(required, ",") {
+				return errors.New("denied by policy")
+			}
+
+			return nil
+		}))
+
+	fw.Get("/reports", helper.HandlerFactory(200, "reports"), framework.Require("reports:read"))
+
+	rr := httptest.NewRecorder()
+	fw.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/reports", nil))
+	assert.Equal(t, 403, rr.Code)
+	assert.Equal(t, `{"code":403,"msg":"denied by policy"}`, strings.TrimSpace(rr.Body.String()))
+
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+	req.Header.Set("X-Grant", "reports:read")
+	fw.ServeHTTP(rr, req)
+	assert.Equal(t, 200, rr.Code)
+}
+
+func TestFramework_WithChallenge(t *testing.T) {
+	tests := map[string]struct {
+		challengers []framework.Challenger
+		want        []string
+	}{
+		"should send the default challenge": {
+			want: []string{"Bearer"},
+		},
+		"should send the challenges of the authenticators": {
+			challengers: []framework.Challenger{
+				auth.NewBasic("api", nil),
+				auth.NewAPIKey(auth.APIKeyConfig{}),
+			},
+			want: []string{`Basic realm="api", charset="UTF-8"`, `APIKey header="X-API-Key"`},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			fw := framework.New().WithChallenge(tt.challengers...)
+			fw.Get("/reports", helper.HandlerFactory(200, "reports"),
+				framework.Require("reports:read"))
+
+			rr := httptest.NewRecorder()
+			fw.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/reports", nil))
+
+			assert.Equal(t, 401, rr.Code)
+			assert.Equal(t, tt.want, rr.Header().Values("WWW-Authenticate"))
+		})
+	}
+}
+
+func TestFramework_Routes(t *testing.T) {
+	fw := framework.New()
+	fw.Get("/", helper.HandlerFactory(200, "root"))
+	fw.WithPrefix("/api", func() {
+		fw.Get("/users", helper.HandlerFactory(200, "list"), framework.Require("users:read"))
+		fw.Delete("/users/:id", helper.HandlerFactory(200, "delete"),
+			framework.Require("users:write"))
+	}, framework.Require("staff"))
+
+	assert.Equal(t, []framework.RouteInfo{
+		{Method: "GET", Pattern: "/", Permissions: nil},
+		{Method: "GET", Pattern: "/api/users", Permissions: []string{"staff", "users:read"}},
+		{Method: "DELETE", Pattern: "/api/users/:id", Permissions: []string{"staff", "users:write"}},
+	}, fw.Routes())
+
+	fw.Clear()
+	assert.Empty(t, fw.Routes())
+}
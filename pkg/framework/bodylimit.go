@@ -0,0 +1,36 @@
+package framework
+
+import (
+	"net/http"
+
+	"github.com/snobb/susanin/pkg/middleware"
+)
+
+// limitBody limits the request body with http.MaxBytesReader. The route limit takes precedence
+// over the Framework one. Requests with Content-Length over the limit are rejected straight
+// away, otherwise the handler response is replaced with HTTP 413 if the limit was hit before
+// the handler started writing the response.
+func (fw *Framework) limitBody(cfg *routeConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := fw.settings().maxBodySize
+		if cfg.maxBodySize != nil {
+			limit = *cfg.maxBodySize
+		}
+
+		if limit <= 0 || r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.ContentLength > limit {
+			returnError(w, "Request body is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		w, r.Body = middleware.LimitBody(w, r.Body, limit, func(w http.ResponseWriter) {
+			returnError(w, "Request body is too large", http.StatusRequestEntityTooLarge)
+		})
+
+		next.ServeHTTP(w, r)
+	})
+}
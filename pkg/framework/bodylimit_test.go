@@ -0,0 +1,102 @@
+package framework_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/framework"
+)
+
+func TestFramework_MaxBodySize(t *testing.T) {
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(400)
+			return
+		}
+
+		_, _ = w.Write(body)
+	}
+
+	tests := map[string]struct {
+		path     string
+		body     string
+		chunked  bool
+		wantCode int
+		wantBody string
+	}{
+		"should accept the body within the framework limit": {
+			path:     "/default",
+			body:     "12345678",
+			wantCode: 200,
+			wantBody: "12345678",
+		},
+		"should reject the body over the framework limit": {
+			path:     "/default",
+			body:     "123456789",
+			wantCode: 413,
+			wantBody: `{"code":413,"msg":"Request body is too large"}`,
+		},
+		"should reject the body of unknown length over the limit": {
+			path:     "/default",
+			body:     "123456789",
+			chunked:  true,
+			wantCode: 413,
+			wantBody: `{"code":413,"msg":"Request body is too large"}`,
+		},
+		"should accept the body within the route limit": {
+			path:     "/route",
+			body:     "1234567890ab",
+			wantCode: 200,
+			wantBody: "1234567890ab",
+		},
+		"should reject the body over the group limit": {
+			path:     "/small/group",
+			body:     "12345",
+			wantCode: 413,
+			wantBody: `{"code":413,"msg":"Request body is too large"}`,
+		},
+		"should use the route limit inside the group": {
+			path:     "/small/route",
+			body:     "12345678",
+			chunked:  true,
+			wantCode: 200,
+			wantBody: "12345678",
+		},
+		"should not limit if the route limit is disabled": {
+			path:     "/unlimited",
+			body:     strings.Repeat("x", 1024),
+			wantCode: 200,
+			wantBody: strings.Repeat("x", 1024),
+		},
+	}
+
+	fw := framework.New().WithMaxBodySize(8)
+	fw.Post("/default", http.HandlerFunc(echo))
+	fw.Post("/route", http.HandlerFunc(echo), framework.MaxBodySize(16))
+	fw.Post("/unlimited", http.HandlerFunc(echo), framework.MaxBodySize(0))
+	fw.WithPrefix("/small", func() {
+		fw.Post("/group", http.HandlerFunc(echo))
+		fw.Post("/route", http.HandlerFunc(echo), framework.MaxBodySize(8))
+	}, framework.MaxBodySize(4))
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+
+			fw.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+			assert.Equal(t, tt.wantBody, strings.TrimSpace(rr.Body.String()))
+		})
+	}
+}
@@ -7,6 +7,9 @@ package framework
 import (
 	"net/http"
 	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/snobb/susanin/pkg/middleware"
 )
@@ -22,17 +25,59 @@ const (
 	mSize
 )
 
+var methodNames = [mSize]string{
+	http.MethodGet,
+	http.MethodPut,
+	http.MethodPost,
+	http.MethodDelete,
+	http.MethodPatch,
+	http.MethodHead,
+	http.MethodOptions,
+}
+
 // Route callback function
 type Route func()
 
-// Framework is a web framework main data structure
+// RouteInfo describes a registered route
+type RouteInfo struct {
+	Host        string
+	Method      string
+	Pattern     string
+	Permissions []string
+}
+
+// Framework is a web framework main data structure. The routes can be registered and removed
+// while serving the requests. The WithPrefix and WithHost groups are shared by the Framework,
+// the routes can be registered from several goroutines at once with the Group values instead.
 type Framework struct {
-	methods         [mSize]*Router
 	middlewares     []middleware.Middleware
 	prefixes        []string
+	options         []RouteOption
 	notFoundHandler http.Handler
+	host            string // host group being registered
+	routerOptions   []RouterOption
+	strictSlash     bool // resolved from the router options
+
+	mu     sync.Mutex // guards the routes, the group state and the settings changes
+	swapMu sync.Mutex // serialises Swap
+	routes routeSet
+	swap   *swapState   // swap in progress, nil if none
+	table  atomic.Value // *routeTable being served
+	dirty  int32        // the served table is out of date
+	conf   atomic.Value // *settings read while serving
 }
 
+// settings are the Framework settings read while serving the requests. They are replaced as
+// a whole, so they can be changed while serving.
+type settings struct {
+	chain       http.Handler // middlewares combined with dispatch
+	maxBodySize int64
+	policy      Policy
+	challengers []Challenger
+}
+
+var defaultSettings = &settings{}
+
 // New is the Framework constructor
 func New() *Framework {
 	return &Framework{}
@@ -43,15 +88,29 @@ func New() *Framework {
 // As an alternative http.StripPrefix can be used to wrap the main Framework instance. That will
 // have the same effect as setting the prefix with this method right after creating the instance.
 func (fw *Framework) WithDefaultPrefix(prefix string) *Framework {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
 	fw.prefixes = append(fw.prefixes, prefix)
 	return fw
 }
 
-// WithPrefix registers paths with given prefix.
-func (fw *Framework) WithPrefix(prefix string, route Route) *Framework {
+// WithPrefix registers paths with given prefix. The options are applied to all the routes
+// registered within the group. The group is shared by the Framework, so the routes registered
+// with the Framework by other goroutines meanwhile get the prefix as well. Use Group to
+// register the routes concurrently.
+func (fw *Framework) WithPrefix(prefix string, route Route, opts ...RouteOption) *Framework {
+	fw.mu.Lock()
 	fw.prefixes = append(fw.prefixes, prefix)
+	nopts := len(fw.options)
+	fw.options = append(fw.options, opts...)
+	fw.mu.Unlock()
+
 	defer func() {
+		fw.mu.Lock()
 		fw.prefixes = fw.prefixes[:len(fw.prefixes)-1]
+		fw.options = fw.options[:nopts]
+		fw.mu.Unlock()
 	}()
 
 	route()
@@ -60,29 +119,160 @@ func (fw *Framework) WithPrefix(prefix string, route Route) *Framework {
 
 // WithNotFoundHandler sets NotFoundHander that will be used in case the route is not found.
 func (fw *Framework) WithNotFoundHandler(notFoundHander http.Handler) *Framework {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
 	fw.notFoundHandler = notFoundHander
+	fw.routes.staging = nil
+	if fw.swap != nil {
+		fw.swap.routes.staging = nil
+	}
+
+	atomic.StoreInt32(&fw.dirty, 1)
+	return fw
+}
+
+// WithMaxBodySize limits the request body size for all routes. The limit can be overridden per
+// route or group with the MaxBodySize option. Requests exceeding the limit are responded with
+// HTTP 413. Zero or negative size disables the limit (default).
+func (fw *Framework) WithMaxBodySize(size int64) *Framework {
+	fw.configure(func(s *settings) {
+		s.maxBodySize = size
+	})
+
+	return fw
+}
+
+// WithRouterOptions sets the path matching options of the routers, e.g. CleanPath or
+// CaseInsensitive. The options must be set before any route is registered, otherwise the
+// function panics.
+func (fw *Framework) WithRouterOptions(opts ...RouterOption) *Framework {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	if len(fw.routes.defs) != 0 {
+		panic("router options must be set before the routes are registered")
+	}
+
+	fw.routerOptions = append(fw.routerOptions, opts...)
+
+	// the options are resolved once rather than on every registration
+	var rt Router
+	for _, opt := range fw.routerOptions {
+		opt(&rt)
+	}
+
+	fw.strictSlash = rt.strictSlash
 	return fw
 }
 
 // Attach adds middleware to the chain
 func (fw *Framework) Attach(middlewares ...middleware.Middleware) *Framework {
-	fw.middlewares = append(fw.middlewares, middlewares...)
+	fw.configure(func(s *settings) {
+		fw.middlewares = append(fw.middlewares, middlewares...)
+
+		// the chain is combined once rather than on every request
+		var h http.Handler = http.HandlerFunc(fw.dispatch)
+		for i := 0; i < len(fw.middlewares); i++ {
+			h = fw.middlewares[i](h)
+		}
+
+		s.chain = h
+	})
+
 	return fw
 }
 
-func (fw *Framework) handler(method int, pattern string, handler http.Handler) {
-	if fw.methods[method] == nil {
-		fw.methods[method] = NewRouter(fw.notFoundHandler)
+// settings returns the settings to serve the request with.
+func (fw *Framework) settings() *settings {
+	if s, ok := fw.conf.Load().(*settings); ok {
+		return s
 	}
 
-	rt := fw.methods[method]
+	return defaultSettings
+}
+
+// configure changes a copy of the settings and publishes it.
+func (fw *Framework) configure(change func(s *settings)) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
 
-	pp := append([]string{}, fw.prefixes...)
+	s := *fw.settings()
+	change(&s)
+	fw.conf.Store(&s)
+}
+
+// scope returns a copy of the group state of the Framework.
+func (fw *Framework) scope() scope {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	return scope{
+		prefixes: append([]string(nil), fw.prefixes...),
+		options:  append([]RouteOption(nil), fw.options...),
+		host:     fw.host,
+	}
+}
+
+func (fw *Framework) handler(method int, pattern string, handler http.Handler, opts []RouteOption) {
+	fw.handle(nil, fw.scope(), method, pattern, handler, opts)
+}
+
+// handle registers the route of the group. The routes of a swap group are registered for the
+// swap, the other ones are served straight away.
+func (fw *Framework) handle(sw *swapState, s scope, method int, pattern string,
+	handler http.Handler, opts []RouteOption) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	pp := append([]string{}, s.prefixes...)
 	pp = append(pp, pattern)
 
-	if err := rt.Handle(path.Join(pp...), handler); err != nil {
-		panic(err)
+	slash := fw.strictSlash && strings.HasSuffix(pattern, "/")
+	if pattern = path.Join(pp...); slash && pattern != "/" {
+		pattern += "/"
+	}
+	cfg := newRouteConfig(s.options, opts)
+
+	def := routeDef{
+		method:   method,
+		pattern:  pattern,
+		handler:  fw.wrap(pattern, cfg, handler),
+		matchers: cfg.matchers,
+		info: RouteInfo{
+			Host:        s.host,
+			Method:      methodNames[method],
+			Pattern:     pattern,
+			Permissions: append([]string(nil), cfg.permissions...),
+		},
+	}
+
+	if sw != nil {
+		fw.checkSwap(sw)
+		fw.register(&sw.routes, def)
+		return
+	}
+
+	fw.register(&fw.routes, def)
+	if fw.swap != nil {
+		fw.swap.concurrent = append(fw.swap.concurrent, def)
 	}
+
+	atomic.StoreInt32(&fw.dirty, 1)
+}
+
+// Routes returns the registered routes in the order of registration along with the
+// permissions they require.
+func (fw *Framework) Routes() []RouteInfo {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	routes := make([]RouteInfo, 0, len(fw.routes.defs))
+	for _, def := range fw.routes.defs {
+		routes = append(routes, def.info)
+	}
+
+	return routes
 }
 
 func (fw *Framework) dispatch(w http.ResponseWriter, r *http.Request) {
@@ -115,64 +305,88 @@ func (fw *Framework) dispatch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rt := fw.methods[method]
+	t := fw.current()
+
+	ps := acquireParams()
+	defer releaseParams(ps)
+
+	if hr := t.matchHost(r, ps); hr != nil {
+		if rt := hr.methods[method]; rt != nil {
+			if handler := rt.lookup(r.URL.EscapedPath(), r, ps); handler != nil {
+				serveRoute(w, r, handler, *ps)
+				return
+			}
+		}
+
+		// the host values are not passed to the routes without a host
+		*ps = (*ps)[:0]
+	}
+
+	rt := t.methods[method]
 	if rt == nil {
 		returnError(w, "Method is not found", 404)
 		return
 	}
 
-	rt.RouterHandler(w, r)
+	rt.serve(w, r, ps)
 }
 
 // ServeHTTP is the implementation of the http.Handler interface
-// It combines the chain and serves the HTTP requests.
+// It serves the HTTP requests with the middleware chain.
 func (fw *Framework) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	var h http.Handler = http.HandlerFunc(fw.dispatch)
-	for i := 0; i < len(fw.middlewares); i++ {
-		h = fw.middlewares[i](h)
+	chain := fw.settings().chain
+	if chain == nil {
+		fw.dispatch(w, r)
+		return
 	}
 
-	h.ServeHTTP(w, r)
+	chain.ServeHTTP(w, r)
 }
 
 // Get adds handler for GET requests
-func (fw *Framework) Get(path string, handler http.Handler) {
-	fw.handler(mGet, path, handler)
+func (fw *Framework) Get(path string, handler http.Handler, opts ...RouteOption) {
+	fw.handler(mGet, path, handler, opts)
 }
 
 // Put adds handler for PUT requests
-func (fw *Framework) Put(path string, handler http.Handler) {
-	fw.handler(mPut, path, handler)
+func (fw *Framework) Put(path string, handler http.Handler, opts ...RouteOption) {
+	fw.handler(mPut, path, handler, opts)
 }
 
 // Post adds handler for POST requests
-func (fw *Framework) Post(path string, handler http.Handler) {
-	fw.handler(mPost, path, handler)
+func (fw *Framework) Post(path string, handler http.Handler, opts ...RouteOption) {
+	fw.handler(mPost, path, handler, opts)
 }
 
 // Delete adds handler for DELETE requests
-func (fw *Framework) Delete(path string, handler http.Handler) {
-	fw.handler(mDelete, path, handler)
+func (fw *Framework) Delete(path string, handler http.Handler, opts ...RouteOption) {
+	fw.handler(mDelete, path, handler, opts)
 }
 
 // Patch adds handler for PATCH requests
-func (fw *Framework) Patch(path string, handler http.Handler) {
-	fw.handler(mPatch, path, handler)
+func (fw *Framework) Patch(path string, handler http.Handler, opts ...RouteOption) {
+	fw.handler(mPatch, path, handler, opts)
 }
 
 // Head adds handler for PATCH requests
-func (fw *Framework) Head(path string, handler http.Handler) {
-	fw.handler(mHead, path, handler)
+func (fw *Framework) Head(path string, handler http.Handler, opts ...RouteOption) {
+	fw.handler(mHead, path, handler, opts)
 }
 
 // Options adds handler for PATCH requests
-func (fw *Framework) Options(path string, handler http.Handler) {
-	fw.handler(mOptions, path, handler)
+func (fw *Framework) Options(path string, handler http.Handler, opts ...RouteOption) {
+	fw.handler(mOptions, path, handler, opts)
 }
 
 // Clear clears all handlers for all methods
 func (fw *Framework) Clear() {
-	for i := 0; i < mSize; i++ {
-		fw.methods[i] = nil
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	fw.routes = routeSet{staging: &routeTable{}}
+	if fw.swap != nil {
+		fw.swap.concurrent = nil
 	}
+
+	atomic.StoreInt32(&fw.dirty, 1)
 }
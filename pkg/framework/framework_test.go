@@ -6,11 +6,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/snobb/susanin/pkg/framework"
+	"github.com/snobb/susanin/pkg/middleware/auth"
 	"github.com/snobb/susanin/test/helper"
 )
 
@@ -209,3 +211,47 @@ func TestFramework_Post_Put_Patch(t *testing.T) {
 		assert.Equal(t, 404, rr.Code)
 	}
 }
+
+// TestFramework_Settings_Concurrent changes the settings while serving. It is meant to be run
+// with the race detector.
+func TestFramework_Settings_Concurrent(t *testing.T) {
+	fw := framework.New()
+	fw.Post("/items", helper.HandlerFactory(200, "items"))
+	fw.Get("/admin", helper.HandlerFactory(200, "admin"), framework.Require("admin"))
+
+	var wg sync.WaitGroup
+
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader("body"))
+				fw.ServeHTTP(httptest.NewRecorder(), req)
+				serve(fw, http.MethodGet, "", "/admin")
+			}
+		}()
+	}
+
+	for i := 0; i < 50; i++ {
+		fw.WithMaxBodySize(int64(i))
+		fw.WithPolicy(framework.PrincipalPolicy)
+		fw.WithChallenge(auth.NewBasic("api", nil))
+		fw.Attach(headerMiddleware("global"))
+	}
+
+	close(stop)
+	wg.Wait()
+
+	rec := serve(fw, http.MethodGet, "", "/admin")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Len(t, rec.Header()["X-Group"], 50)
+	assert.Len(t, rec.Header()["Www-Authenticate"], 50)
+}
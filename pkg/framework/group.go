@@ -0,0 +1,101 @@
+package framework
+
+import "net/http"
+
+// scope is the state of a group of routes.
+type scope struct {
+	prefixes []string
+	options  []RouteOption
+	host     string
+}
+
+// Group registers the routes with a common prefix, options and host. Unlike WithPrefix and
+// WithHost, the group state is held by the Group value rather than the Framework, so the
+// groups can be used to register the routes from several goroutines at once.
+type Group struct {
+	fw    *Framework
+	swap  *swapState // swap the routes are registered for, nil for the served routes
+	scope scope
+}
+
+// Group returns a group registering the routes with the prefix and the options. The group
+// inherits the default prefixes and the WithPrefix and WithHost groups being registered.
+func (fw *Framework) Group(prefix string, opts ...RouteOption) *Group {
+	return (&Group{fw: fw, scope: fw.scope()}).Group(prefix, opts...)
+}
+
+// Host returns a group registering the routes served for the requests to the hosts matching
+// the pattern. See WithHost for the host patterns.
+func (fw *Framework) Host(pattern string, opts ...RouteOption) *Group {
+	return (&Group{fw: fw, scope: fw.scope()}).Host(pattern, opts...)
+}
+
+// Group returns a nested group registering the routes with the prefix and the options added
+// to the ones of the group.
+func (g *Group) Group(prefix string, opts ...RouteOption) *Group {
+	return &Group{
+		fw:   g.fw,
+		swap: g.swap,
+		scope: scope{
+			prefixes: append(append([]string{}, g.scope.prefixes...), prefix),
+			options:  append(append([]RouteOption{}, g.scope.options...), opts...),
+			host:     g.scope.host,
+		},
+	}
+}
+
+// Host returns a nested group registering the routes of the hosts matching the pattern. The
+// host groups cannot be nested.
+func (g *Group) Host(pattern string, opts ...RouteOption) *Group {
+	checkHost(pattern)
+
+	if g.scope.host != "" {
+		panic("host groups cannot be nested")
+	}
+
+	hg := g.Group("", opts...)
+	hg.scope.host = pattern
+
+	return hg
+}
+
+// Remove removes the routes of the group host registered for the method and the pattern as
+// listed by Routes. See Framework.Remove.
+func (g *Group) Remove(method, pattern string) bool {
+	return g.fw.remove(g.swap, g.scope.host, method, pattern)
+}
+
+// Get adds handler for GET requests
+func (g *Group) Get(path string, handler http.Handler, opts ...RouteOption) {
+	g.fw.handle(g.swap, g.scope, mGet, path, handler, opts)
+}
+
+// Put adds handler for PUT requests
+func (g *Group) Put(path string, handler http.Handler, opts ...RouteOption) {
+	g.fw.handle(g.swap, g.scope, mPut, path, handler, opts)
+}
+
+// Post adds handler for POST requests
+func (g *Group) Post(path string, handler http.Handler, opts ...RouteOption) {
+	g.fw.handle(g.swap, g.scope, mPost, path, handler, opts)
+}
+
+// Delete adds handler for DELETE requests
+func (g *Group) Delete(path string, handler http.Handler, opts ...RouteOption) {
+	g.fw.handle(g.swap, g.scope, mDelete, path, handler, opts)
+}
+
+// Patch adds handler for PATCH requests
+func (g *Group) Patch(path string, handler http.Handler, opts ...RouteOption) {
+	g.fw.handle(g.swap, g.scope, mPatch, path, handler, opts)
+}
+
+// Head adds handler for HEAD requests
+func (g *Group) Head(path string, handler http.Handler, opts ...RouteOption) {
+	g.fw.handle(g.swap, g.scope, mHead, path, handler, opts)
+}
+
+// Options adds handler for OPTIONS requests
+func (g *Group) Options(path string, handler http.Handler, opts ...RouteOption) {
+	g.fw.handle(g.swap, g.scope, mOptions, path, handler, opts)
+}
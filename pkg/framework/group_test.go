@@ -0,0 +1,139 @@
+package framework_test
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/framework"
+	"github.com/snobb/susanin/pkg/middleware"
+	"github.com/snobb/susanin/test/helper"
+)
+
+func headerMiddleware(name string) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Group", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestGroup(t *testing.T) {
+	fw := framework.New()
+	fw.WithDefaultPrefix("/site")
+
+	api := fw.Group("/api", framework.Use(headerMiddleware("api")))
+	api.Get("/status", helper.HandlerFactory(200, "status"))
+
+	v1 := api.Group("/v1", framework.Use(headerMiddleware("v1")))
+	v1.Get("/items/:id", helper.HandlerFactory(200, "item"))
+
+	v1.Host("{tenant}.example.com").Get("/items/:id", valuesHandler("tenant"))
+
+	tests := map[string]struct {
+		host       string
+		path       string
+		wantBody   string
+		wantHeader []string
+	}{
+		"should register the routes with the prefix": {
+			path:       "/site/api/status",
+			wantBody:   "status",
+			wantHeader: []string{"api"},
+		},
+		"should combine the nested groups": {
+			path:       "/site/api/v1/items/1",
+			wantBody:   "item",
+			wantHeader: []string{"api", "v1"},
+		},
+		"should register the routes of the host": {
+			host:       "acme.example.com",
+			path:       "/site/api/v1/items/1",
+			wantBody:   `tenant {"id":"1","tenant":"acme"}`,
+			wantHeader: []string{"api", "v1"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := serve(fw, http.MethodGet, tt.host, tt.path)
+			assert.Equal(t, 200, rec.Code)
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+			assert.Equal(t, tt.wantHeader, rec.Header()["X-Group"])
+		})
+	}
+
+	host := fw.Host("{tenant}.example.com")
+	assert.False(t, host.Remove(http.MethodGet, "/site/api/status"))
+	assert.True(t, host.Remove(http.MethodGet, "/site/api/v1/items/:id"))
+	assert.Equal(t, "item", serve(fw, http.MethodGet, "acme.example.com",
+		"/site/api/v1/items/1").Body.String())
+}
+
+func TestGroup_Invalid(t *testing.T) {
+	tests := map[string]func(fw *framework.Framework){
+		"should panic on nested host groups": func(fw *framework.Framework) {
+			fw.Host("api.example.com").Group("/v1").Host("admin.example.com")
+		},
+		"should panic on host group within WithHost": func(fw *framework.Framework) {
+			fw.WithHost("api.example.com", func() {
+				fw.Host("admin.example.com")
+			})
+		},
+		"should panic on invalid host pattern": func(fw *framework.Framework) {
+			fw.Host("api..com")
+		},
+		"should panic on conflicting routes": func(fw *framework.Framework) {
+			fw.Group("/api").Get("/items", helper.HandlerFactory(200, "items"))
+			fw.Get("/api/items", helper.HandlerFactory(200, "items"))
+		},
+	}
+
+	for name, register := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Panics(t, func() { register(framework.New()) })
+		})
+	}
+}
+
+// TestGroup_Concurrent registers the routes with the groups from several goroutines while
+// another one registers with WithPrefix. It is meant to be run with the race detector.
+func TestGroup_Concurrent(t *testing.T) {
+	fw := framework.New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			g := fw.Group(fmt.Sprintf("/group/%d", i))
+			for j := 0; j < 50; j++ {
+				g.Get(fmt.Sprintf("/%d", j), helper.HandlerFactory(200, "group"))
+			}
+		}(i)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 50; j++ {
+			fw.WithPrefix("/prefix", func() {
+				fw.Get(fmt.Sprintf("/%d", j), helper.HandlerFactory(200, "prefix"))
+			})
+		}
+	}()
+
+	wg.Wait()
+
+	// the group routes do not get the prefix of the WithPrefix group
+	for _, route := range fw.Routes() {
+		assert.Regexp(t, `^/(group/\d|prefix)/\d+$`, route.Pattern)
+	}
+
+	assert.Len(t, fw.Routes(), 250)
+}
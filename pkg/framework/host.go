@@ -0,0 +1,177 @@
+package framework
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type forwardedKey struct{}
+
+// Forwarded is the client view of the request resolved from the forwarding headers and stored
+// in the request context by a middleware, e.g. the proxy one. The host routes are matched with
+// its host instead of the Request.Host.
+type Forwarded interface {
+	// Host returns the host requested by the client.
+	Host() string
+}
+
+// WithForwarded returns a copy of the context with the client view stored.
+func WithForwarded(ctx context.Context, f Forwarded) context.Context {
+	return context.WithValue(ctx, forwardedKey{}, f)
+}
+
+// GetForwarded gets the client view from the context.
+func GetForwarded(ctx context.Context) (Forwarded, bool) {
+	f, ok := ctx.Value(forwardedKey{}).(Forwarded)
+	return f, ok && f != nil
+}
+
+// hostRoutes are the routes of a host pattern.
+type hostRoutes struct {
+	pattern string
+	labels  []string // reversed host labels, e.g. ["com", "example", "{tenant}"]
+	vars    int      // number of variable labels
+	methods [mSize]*Router
+}
+
+// newHostRoutes parses the host pattern. The pattern labels are either literal, a variable,
+// e.g. "{tenant}", matching a single label, or "*" matching one or more leading labels.
+func newHostRoutes(pattern string) (*hostRoutes, error) {
+	host := strings.TrimSuffix(strings.ToLower(pattern), ".")
+	if host == "" {
+		return nil, fmt.Errorf("invalid host pattern: %q", pattern)
+	}
+
+	labels := strings.Split(host, ".")
+	hr := &hostRoutes{pattern: pattern, labels: make([]string, len(labels))}
+
+	for i, label := range labels {
+		switch {
+		case label == "*":
+			if i != 0 {
+				return nil, fmt.Errorf("invalid host pattern: %q: wildcard must be the first label",
+					pattern)
+			}
+
+		case strings.HasPrefix(label, "{") && strings.HasSuffix(label, "}") && len(label) > 2:
+			hr.vars++
+
+		case label == "" || strings.ContainsAny(label, "{}*"):
+			return nil, fmt.Errorf("invalid host pattern: %q", pattern)
+		}
+
+		hr.labels[len(labels)-1-i] = label
+	}
+
+	return hr, nil
+}
+
+// exact reports if the pattern has neither variables nor a wildcard.
+func (hr *hostRoutes) exact() bool {
+	return hr.vars == 0 && hr.labels[len(hr.labels)-1] != "*"
+}
+
+// match matches the host labels, reversed, and appends the variables to the params.
+func (hr *hostRoutes) match(labels []string, ps *Params) bool {
+	wildcard := hr.labels[len(hr.labels)-1] == "*"
+
+	if wildcard && len(labels) < len(hr.labels) || !wildcard && len(labels) != len(hr.labels) {
+		return false
+	}
+
+	n := len(*ps)
+
+	for i, label := range hr.labels {
+		switch {
+		case label == "*":
+			return true
+
+		case label[0] == '{':
+			*ps = append(*ps, Param{Key: label[1 : len(label)-1], Value: labels[i]})
+
+		case label != labels[i]:
+			*ps = (*ps)[:n]
+			return false
+		}
+	}
+
+	return true
+}
+
+// WithHost registers the routes served for the requests to the hosts matching the pattern.
+// The pattern is either an exact host name, e.g. "api.example.com", has variable labels, e.g.
+// "{tenant}.example.com", or starts with a wildcard label, e.g. "*.example.com", that matches
+// one or more labels. The host variables are merged into the GetValues map.
+// Exact hosts are tried first and then the patterns in the order of registration. The requests
+// that do not match any of the host routes fall back to the routes registered without a host,
+// which do not get the host variables.
+// The options are applied to all the routes registered within the group. Like WithPrefix, the
+// group is shared by the Framework, use Host to register the routes concurrently.
+func (fw *Framework) WithHost(pattern string, route Route, opts ...RouteOption) *Framework {
+	checkHost(pattern)
+
+	fw.mu.Lock()
+	if fw.host != "" {
+		fw.mu.Unlock()
+		panic("host groups cannot be nested")
+	}
+
+	fw.host = pattern
+	fw.mu.Unlock()
+
+	defer func() {
+		fw.mu.Lock()
+		fw.host = ""
+		fw.mu.Unlock()
+	}()
+
+	return fw.WithPrefix("", route, opts...)
+}
+
+// checkHost panics if the host pattern is invalid.
+func checkHost(pattern string) {
+	if _, err := newHostRoutes(pattern); err != nil {
+		panic(err)
+	}
+}
+
+// matchHost finds the host routes of the request host appending the host variables to the
+// params. The Forwarded host is used if it is stored in the request context.
+func (t *routeTable) matchHost(r *http.Request, ps *Params) *hostRoutes {
+	if len(t.hosts) == 0 {
+		return nil
+	}
+
+	host := r.Host
+	if f, ok := GetForwarded(r.Context()); ok {
+		host = f.Host()
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	labels := strings.Split(strings.TrimSuffix(strings.ToLower(host), "."), ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+
+	if hr, ok := t.exactHosts[strings.Join(labels, ".")]; ok {
+		return hr
+	}
+
+	for _, hr := range t.hosts {
+		if hr.exact() {
+			continue
+		}
+
+		if hr.match(labels, ps) {
+			return hr
+		}
+	}
+
+	return nil
+}
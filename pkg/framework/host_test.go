@@ -0,0 +1,214 @@
+package framework_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/framework"
+	"github.com/snobb/susanin/pkg/middleware/proxy"
+	"github.com/snobb/susanin/test/helper"
+)
+
+func valuesHandler(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		values, _ := framework.GetValues(r.Context())
+		body, _ := json.Marshal(values)
+		_, _ = w.Write([]byte(name + " " + string(body)))
+	})
+}
+
+func TestFramework_WithHost(t *testing.T) {
+	fw := framework.New()
+	fw.Get("/health", helper.HandlerFactory(200, "health"))
+	fw.Get("/users/:id", valuesHandler("default"))
+
+	fw.WithHost("api.example.com", func() {
+		fw.Get("/users/:id", valuesHandler("api"))
+	})
+
+	fw.WithHost("admin.example.com", func() {
+		fw.WithPrefix("/admin", func() {
+			fw.Get("/users/:id", valuesHandler("admin"))
+		})
+	})
+
+	fw.WithHost("{tenant}.example.com", func() {
+		fw.Get("/users/:id", valuesHandler("tenant"))
+	})
+
+	fw.WithHost("*.cdn.example.net", func() {
+		fw.Get("/*", helper.HandlerFactory(200, "cdn"))
+	})
+
+	fw.WithHost("{region}.{env}.example.org", func() {
+		fw.Get("/", valuesHandler("region"))
+	})
+
+	tests := map[string]struct {
+		host     string
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		"should route exact host": {
+			host:     "api.example.com",
+			path:     "/users/1",
+			wantCode: 200,
+			wantBody: `api {"id":"1"}`,
+		},
+		"should ignore port and case of the host": {
+			host:     "API.Example.com:8080",
+			path:     "/users/1",
+			wantCode: 200,
+			wantBody: `api {"id":"1"}`,
+		},
+		"should route exact host with prefix": {
+			host:     "admin.example.com",
+			path:     "/admin/users/2",
+			wantCode: 200,
+			wantBody: `admin {"id":"2"}`,
+		},
+		"should prefer exact host over variable": {
+			host:     "admin.example.com",
+			path:     "/users/2",
+			wantCode: 200,
+			wantBody: `default {"id":"2"}`,
+		},
+		"should merge host variables into values": {
+			host:     "acme.example.com",
+			path:     "/users/3",
+			wantCode: 200,
+			wantBody: `tenant {"id":"3","tenant":"acme"}`,
+		},
+		"should match several host variables": {
+			host:     "eu.prod.example.org",
+			path:     "/",
+			wantCode: 200,
+			wantBody: `region {"env":"prod","region":"eu"}`,
+		},
+		"should not pass host variables to fallback routes": {
+			host:     "eu.prod.example.org",
+			path:     "/users/4",
+			wantCode: 200,
+			wantBody: `default {"id":"4"}`,
+		},
+		"should not match variable label with several labels": {
+			host:     "a.b.example.com",
+			path:     "/users/5",
+			wantCode: 200,
+			wantBody: `default {"id":"5"}`,
+		},
+		"should match wildcard with several labels": {
+			host:     "a.b.cdn.example.net",
+			path:     "/img/logo.png",
+			wantCode: 200,
+			wantBody: "cdn",
+		},
+		"should not match wildcard without labels": {
+			host:     "cdn.example.net",
+			path:     "/img/logo.png",
+			wantCode: 404,
+			wantBody: `{"code":404,"msg":"Endpoint is not found"}`,
+		},
+		"should fall back to routes without host": {
+			host:     "api.example.com",
+			path:     "/health",
+			wantCode: 200,
+			wantBody: "health",
+		},
+		"should route unknown host to routes without host": {
+			host:     "other.org",
+			path:     "/users/6",
+			wantCode: 200,
+			wantBody: `default {"id":"6"}`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Host = tt.host
+
+			rec := httptest.NewRecorder()
+			fw.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.JSONEq(t, jsonOrString(tt.wantBody), jsonOrString(rec.Body.String()))
+		})
+	}
+}
+
+// jsonOrString wraps non JSON bodies into a JSON string, so they can be compared with JSONEq.
+func jsonOrString(body string) string {
+	if json.Valid([]byte(body)) {
+		return body
+	}
+
+	data, _ := json.Marshal(body)
+	return string(data)
+}
+
+func TestFramework_WithHost_Proxy(t *testing.T) {
+	fw := framework.New()
+	fw.Attach(proxy.New(proxy.Config{TrustedProxies: []string{"10.0.0.0/8"}}))
+	fw.WithHost("api.example.com", func() {
+		fw.Get("/", helper.HandlerFactory(200, "api"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "internal:8080"
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	req.Header.Set("X-Forwarded-Host", "api.example.com")
+
+	rec := httptest.NewRecorder()
+	fw.ServeHTTP(rec, req)
+	assert.Equal(t, "api", rec.Body.String())
+}
+
+func TestFramework_WithHost_Invalid(t *testing.T) {
+	tests := map[string]func(fw *framework.Framework){
+		"should panic on wildcard in the middle": func(fw *framework.Framework) {
+			fw.WithHost("api.*.com", func() {})
+		},
+		"should panic on empty label": func(fw *framework.Framework) {
+			fw.WithHost("api..com", func() {})
+		},
+		"should panic on nested host groups": func(fw *framework.Framework) {
+			fw.WithHost("a.com", func() {
+				fw.WithHost("b.com", func() {})
+			})
+		},
+		"should panic on duplicate route of the host": func(fw *framework.Framework) {
+			fw.WithHost("a.com", func() {
+				fw.Get("/", helper.HandlerFactory(200, "a"))
+			})
+			fw.WithHost("a.com", func() {
+				fw.Get("/", helper.HandlerFactory(200, "a"))
+			})
+		},
+	}
+
+	for name, register := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Panics(t, func() { register(framework.New()) })
+		})
+	}
+}
+
+func TestFramework_WithHost_Routes(t *testing.T) {
+	fw := framework.New()
+	fw.Get("/", helper.HandlerFactory(200, "root"))
+	fw.WithHost("{tenant}.example.com", func() {
+		fw.Get("/", helper.HandlerFactory(200, "tenant"))
+	})
+
+	assert.Equal(t, []framework.RouteInfo{
+		{Method: "GET", Pattern: "/"},
+		{Host: "{tenant}.example.com", Method: "GET", Pattern: "/"},
+	}, fw.Routes())
+}
@@ -0,0 +1,238 @@
+package framework
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// The map based path matching the radix tree replaced. It is kept to check the radix tree
+// against it in the differential tests.
+
+// LegacyLookup is like LookupRequest but it uses the map based path matching.
+func (rt *Router) LegacyLookup(r *http.Request) (http.Handler, map[string]string) {
+	path := r.URL.EscapedPath()
+	ps := Params{}
+
+	handler, target := rt.legacyFind(path, r, &ps)
+
+	if handler == nil && rt.redirectSlash && path != "/" {
+		alt := path + "/"
+		if path[len(path)-1] == '/' {
+			alt = path[:len(path)-1]
+		}
+
+		ps = ps[:0]
+		if handler, target = rt.legacyFind(alt, r, &ps); handler != nil {
+			if target == "" {
+				target = alt
+			}
+
+			return redirect(target, http.StatusPermanentRedirect), nil
+		}
+	}
+
+	if handler == nil {
+		return rt.notFoundHandler, nil
+	}
+
+	if target != "" {
+		return redirect(target, rt.redirectCode), nil
+	}
+
+	return handler, ps.Map()
+}
+
+// legacyFind looks for a handler in the escaped path. The path elements are unescaped before
+// matching, so the escaped slashes are kept in the variable values. If the router redirects to
+// the canonical path and the path differs from it, the canonical path is returned as well.
+func (rt *Router) legacyFind(path string, r *http.Request, ps *Params) (http.Handler, string) {
+	orig := path
+	if rt.cleanPath {
+		path = cleanPath(path)
+	}
+
+	trailingSlash := false
+	if path[0] == '/' {
+		path = path[1:]
+	}
+
+	// clear trailing slash so that it matches /path and /path/ unless the slash is strict
+	if !rt.strictSlash && len(path) > 0 && path[len(path)-1] == '/' {
+		path = path[:len(path)-1]
+		trailingSlash = true
+	}
+
+	st := walkState{path: path, r: r, params: ps}
+
+	if handler := rt.legacyWalk(rt.root, 0, &st); handler != nil {
+		return handler, rt.canonical(orig, path, st.edits, trailingSlash)
+	}
+
+	return nil, ""
+}
+
+// legacyWalk matches the path elements from the offset i starting with the link. The constant
+// elements are tried first, then the mixed ones and the variable. If the rest of the path does
+// not match, the deepest multi element variable and then the deepest splat on the way are
+// tried.
+func (rt *Router) legacyWalk(cur *chainLink, i int, st *walkState) http.Handler {
+	path := st.path
+	canonical := rt.redirectCode != 0
+
+	var splatLink, multiLink *chainLink
+	var splatIdx, multiIdx int
+	var multiParams int // number of params when the multi element variable was found
+	found := true
+
+	for i <= len(path) {
+		end := strings.IndexByte(path[i:], '/')
+		if end == -1 {
+			end = len(path)
+		} else {
+			end += i
+		}
+
+		token := path[i:end]
+
+		if cur.nextSplat != nil {
+			splatLink = cur.nextSplat
+			splatIdx = i
+		}
+
+		if cur.nextMulti != nil {
+			multiLink = cur.nextMulti
+			multiIdx = i
+			multiParams = len(*st.params)
+		}
+
+		// the trailing slash is not a variable value in the strict mode
+		trailing := rt.strictSlash && token == "" && i > 0 && end == len(path)
+		value := unescape(token)
+
+		found = false
+		if next, ok := cur.nextConst[rt.constKey(value)]; ok {
+			cur = next
+			found = true
+
+			if canonical {
+				s := token
+				if next.name != value {
+					s = url.PathEscape(next.name)
+				}
+
+				st.setCanonical(i, end, s)
+			}
+		} else if next, captures := cur.matchMixed(value, rt.caseInsensitive); next != nil {
+			cur = next
+			found = true
+
+			j := 0
+			for _, p := range next.parts {
+				if p.name != "" {
+					*st.params = append(*st.params, Param{Key: p.name, Value: captures[j]})
+					j++
+				}
+			}
+
+			if canonical {
+				s := token
+				if name := literalCase(next.parts, captures); name != value {
+					s = url.PathEscape(name)
+				}
+
+				st.setCanonical(i, end, s)
+			}
+		} else if cur.nextVar != nil && !trailing {
+			cur = cur.nextVar
+			*st.params = append(*st.params, Param{Key: cur.name, Value: value})
+			found = true
+
+			if canonical {
+				st.setCanonical(i, end, token)
+			}
+		}
+
+		if !found {
+			break
+		}
+
+		i = end + 1
+	}
+
+	if found {
+		if handler := cur.match(st.r); handler != nil {
+			return handler
+		}
+	}
+
+	// the params of the failed branch are dropped before the multi element variable
+	if multiLink != nil {
+		*st.params = (*st.params)[:multiParams]
+		if handler := rt.legacyWalkMulti(multiLink, multiIdx, st); handler != nil {
+			return handler
+		}
+	}
+
+	if splatLink != nil {
+		if handler := splatLink.match(st.r); handler != nil {
+			// the splat part of the path is kept as is
+			st.resetCanonical(splatIdx, len(path)+1)
+			return handler
+		}
+	}
+
+	return nil
+}
+
+// legacyWalkMulti matches the multi element variable from the offset i taking as many non-empty
+// elements as possible.
+func (rt *Router) legacyWalkMulti(link *chainLink, i int, st *walkState) http.Handler {
+	path := st.path
+	n := len(*st.params)
+
+	// the variable ends before the first empty element
+	end := i
+	for start := i; start <= len(path); {
+		e := strings.IndexByte(path[start:], '/')
+		if e == -1 {
+			e = len(path)
+		} else {
+			e += start
+		}
+
+		if e == start {
+			break
+		}
+
+		end, start = e, e+1
+	}
+
+	for j := end; j > i; {
+		*st.params = (*st.params)[:n]
+
+		var handler http.Handler
+		if j == len(path) {
+			handler = link.match(st.r)
+		} else {
+			handler = rt.legacyWalk(link, j+1, st)
+		}
+
+		if handler != nil {
+			*st.params = append(*st.params, Param{Key: link.name, Value: unescape(path[i:j])})
+			st.resetCanonical(i, j)
+
+			return handler
+		}
+
+		k := strings.LastIndexByte(path[i:j], '/')
+		if k == -1 {
+			break
+		}
+
+		j = i + k
+	}
+
+	*st.params = (*st.params)[:n]
+	return nil
+}
@@ -0,0 +1,86 @@
+package framework
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Match adds request matchers to a route or a group of routes. The route is selected only if
+// all the matchers match the request, so the same method and path can be routed to different
+// handlers, e.g. by the Content-Type. The routes with more matchers take precedence and the
+// routes with the same number of matchers are tried in the order of registration.
+func Match(matchers ...Matcher) RouteOption {
+	return func(cfg *routeConfig) {
+		cfg.matchers = append(cfg.matchers, matchers...)
+	}
+}
+
+// Header matches the requests with the header value. Empty value matches any request having
+// the header.
+func Header(name, value string) Matcher {
+	return func(r *http.Request) bool {
+		values, ok := r.Header[http.CanonicalHeaderKey(name)]
+		if !ok {
+			return false
+		}
+
+		if value == "" {
+			return true
+		}
+
+		for _, v := range values {
+			if v == value {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// Query matches the requests with the query parameter value. Empty value matches any request
+// having the parameter.
+func Query(name, value string) Matcher {
+	return func(r *http.Request) bool {
+		values, ok := r.URL.Query()[name]
+		if !ok {
+			return false
+		}
+
+		if value == "" {
+			return true
+		}
+
+		for _, v := range values {
+			if v == value {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// ContentType matches the requests with any of the media types ignoring the parameters, e.g.
+// "application/json" matches "application/json; charset=utf-8". The types can have a wildcard
+// subtype, e.g. "text/*".
+func ContentType(types ...string) Matcher {
+	return func(r *http.Request) bool {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			return false
+		}
+
+		for _, t := range types {
+			t = strings.ToLower(t)
+
+			if t == mediaType ||
+				strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, t[:len(t)-1]) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
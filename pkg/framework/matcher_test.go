@@ -0,0 +1,146 @@
+package framework_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/framework"
+	"github.com/snobb/susanin/test/helper"
+)
+
+func TestFramework_Match(t *testing.T) {
+	fw := framework.New()
+	fw.Post("/api/items", helper.HandlerFactory(200, "default"))
+	fw.Post("/api/items", helper.HandlerFactory(200, "json"),
+		framework.Match(framework.ContentType("application/json")))
+	fw.Post("/api/items", helper.HandlerFactory(200, "text"),
+		framework.Match(framework.ContentType("text/*")))
+	fw.Post("/api/items", helper.HandlerFactory(200, "json-v2"),
+		framework.Match(
+			framework.ContentType("application/json"),
+			framework.Header("X-Api-Version", "2"),
+		))
+	fw.Post("/api/items", helper.HandlerFactory(200, "dry-run"),
+		framework.Match(framework.Query("dry_run", "")))
+
+	fw.WithPrefix("/files", func() {
+		fw.Get("/*", helper.HandlerFactory(200, "download"))
+		fw.Get("/*", helper.HandlerFactory(200, "preview"),
+			framework.Match(framework.Query("preview", "1")))
+	})
+
+	fw.WithPrefix("/v3", func() {
+		fw.Get("/items", helper.HandlerFactory(200, "v3"))
+	}, framework.Match(framework.Header("X-Api-Version", "3")))
+
+	tests := map[string]struct {
+		method   string
+		path     string
+		header   map[string]string
+		wantCode int
+		wantBody string
+	}{
+		"should route by content type": {
+			path:     "/api/items",
+			header:   map[string]string{"Content-Type": "application/json; charset=utf-8"},
+			wantCode: 200,
+			wantBody: "json",
+		},
+		"should route by wildcard content type": {
+			path:     "/api/items",
+			header:   map[string]string{"Content-Type": "text/csv"},
+			wantCode: 200,
+			wantBody: "text",
+		},
+		"should prefer the route with more matchers": {
+			path: "/api/items",
+			header: map[string]string{
+				"Content-Type":  "application/json",
+				"X-Api-Version": "2",
+			},
+			wantCode: 200,
+			wantBody: "json-v2",
+		},
+		"should prefer the route registered first with the same number of matchers": {
+			path:     "/api/items?dry_run",
+			header:   map[string]string{"Content-Type": "application/json"},
+			wantCode: 200,
+			wantBody: "json",
+		},
+		"should route by query parameter presence": {
+			path:     "/api/items?dry_run",
+			wantCode: 200,
+			wantBody: "dry-run",
+		},
+		"should fall back to the route without matchers": {
+			path:     "/api/items",
+			header:   map[string]string{"Content-Type": "application/xml"},
+			wantCode: 200,
+			wantBody: "default",
+		},
+		"should match splat routes": {
+			method:   http.MethodGet,
+			path:     "/files/a/b.txt?preview=1",
+			wantCode: 200,
+			wantBody: "preview",
+		},
+		"should fall back to splat route without matchers": {
+			method:   http.MethodGet,
+			path:     "/files/a/b.txt?preview=0",
+			wantCode: 200,
+			wantBody: "download",
+		},
+		"should apply group matchers": {
+			method:   http.MethodGet,
+			path:     "/v3/items",
+			header:   map[string]string{"X-Api-Version": "3"},
+			wantCode: 200,
+			wantBody: "v3",
+		},
+		"should not find route if the group matchers do not match": {
+			method:   http.MethodGet,
+			path:     "/v3/items",
+			wantCode: 404,
+			wantBody: `{"code":404,"msg":"Endpoint is not found"}`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+
+			req := httptest.NewRequest(method, tt.path, nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+
+			rec := httptest.NewRecorder()
+			fw.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, tt.wantBody, strings.TrimSpace(rec.Body.String()))
+		})
+	}
+}
+
+func TestRouter_LookupRequest(t *testing.T) {
+	rt := framework.NewRouter(nil)
+	assert.NoError(t, rt.Handle("/items/:id", static, framework.Header("Accept", "text/csv")))
+
+	handler, _ := rt.Lookup("/items/1")
+	assert.Nil(t, handler, "matchers are not evaluated without a request")
+
+	req := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+	req.Header.Set("Accept", "text/csv")
+
+	handler, values := rt.LookupRequest(req)
+	assert.NotNil(t, handler)
+	assert.Equal(t, map[string]string{"id": "1"}, values)
+}
@@ -0,0 +1,134 @@
+package framework
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// RouterOption configures the path matching of a Router.
+type RouterOption func(*Router)
+
+// CleanPath makes the router match the cleaned request path, i.e. with the duplicate slashes
+// and the "." and ".." elements removed, so "//api//v1" and "/api/./v1" match "/api/v1".
+func CleanPath() RouterOption {
+	return func(rt *Router) {
+		rt.cleanPath = true
+	}
+}
+
+// CaseInsensitive makes the router match the constant path elements regardless of case, so
+// "/API/V1" matches "/api/v1". The variable values keep the case of the request path.
+func CaseInsensitive() RouterOption {
+	return func(rt *Router) {
+		rt.caseInsensitive = true
+	}
+}
+
+// RedirectCanonical makes the router redirect the requests to the canonical path of the
+// matched route instead of serving them. The canonical path is the cleaned request path with
+// the constant elements in the case they were registered with, while the variables, the splat
+// part and the trailing slash are kept as requested. The option implies CleanPath.
+// The code is either http.StatusMovedPermanently or http.StatusPermanentRedirect, the latter
+// preserves the method and body of the request. The function panics on other codes.
+func RedirectCanonical(code int) RouterOption {
+	if code != http.StatusMovedPermanently && code != http.StatusPermanentRedirect {
+		panic(fmt.Sprintf("invalid canonical redirect code: %d", code))
+	}
+
+	return func(rt *Router) {
+		rt.cleanPath = true
+		rt.redirectCode = code
+	}
+}
+
+// StrictSlash makes the router distinguish the paths with and without the trailing slash, so
+// "/path" and "/path/" are different routes. The trailing slash of the routes registered with
+// the Framework is kept as well.
+func StrictSlash() RouterOption {
+	return func(rt *Router) {
+		rt.strictSlash = true
+	}
+}
+
+// RedirectTrailingSlash makes the router redirect the requests with HTTP 308 to the other
+// trailing slash form of the path if only that one is registered, e.g. "/docs" to "/docs/".
+// The option implies StrictSlash.
+func RedirectTrailingSlash() RouterOption {
+	return func(rt *Router) {
+		rt.strictSlash = true
+		rt.redirectSlash = true
+	}
+}
+
+// constKey returns the key of the constant path element.
+func (rt *Router) constKey(token string) string {
+	if rt.caseInsensitive {
+		return strings.ToLower(token)
+	}
+
+	return token
+}
+
+// canonical returns the canonical path if the router redirects to it and the request path
+// differs from it. The path is the normalised request path without the leading slash and the
+// cleared trailing one and the edits replace its elements in the case of the route.
+func (rt *Router) canonical(orig, path string, edits []edit, trailingSlash bool) string {
+	if rt.redirectCode == 0 {
+		return ""
+	}
+
+	if len(edits) != 0 {
+		var sb strings.Builder
+
+		prev := 0
+		for _, e := range edits {
+			sb.WriteString(path[prev:e.start])
+			sb.WriteString(e.s)
+			prev = e.end
+		}
+
+		sb.WriteString(path[prev:])
+		path = sb.String()
+	}
+
+	if trailingSlash {
+		path += "/"
+	}
+
+	if len(orig) == len(path)+1 && orig[0] == '/' && orig[1:] == path {
+		return ""
+	}
+
+	return "/" + path
+}
+
+// redirect returns the handler redirecting to the path keeping the query of the request. The
+// leading slashes are collapsed, as the clients treat "//host" and "/\host" as another host.
+func redirect(path string, code int) http.Handler {
+	path = "/" + strings.TrimLeft(path, "/\\")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		location := path
+		if r.URL.RawQuery != "" {
+			location += "?" + r.URL.RawQuery
+		}
+
+		http.Redirect(w, r, location, code)
+	})
+}
+
+// cleanPath returns the shortest equivalent of the path keeping the trailing slash.
+func cleanPath(p string) string {
+	if p == "" || p[0] != '/' {
+		p = "/" + p
+	}
+
+	cleaned := path.Clean(p)
+	if p[len(p)-1] == '/' && cleaned != "/" {
+		cleaned += "/"
+	}
+
+	return cleaned
+}
@@ -0,0 +1,451 @@
+package framework_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/framework"
+	"github.com/snobb/susanin/test/helper"
+)
+
+func TestRouter_Options(t *testing.T) {
+	routes := func(rt *framework.Router) {
+		assert.NoError(t, rt.Handle("/api/v1", static))
+		assert.NoError(t, rt.Handle("/api/Users/:id", dynamic))
+		assert.NoError(t, rt.Handle("/files/*", splat))
+	}
+
+	tests := map[string]struct {
+		opts         []framework.RouterOption
+		path         string
+		wantCode     int
+		wantBody     string
+		wantLocation string
+		wantValues   map[string]string
+	}{
+		"should match exact path by default": {
+			path:     "/api/v1",
+			wantCode: 200,
+			wantBody: "static",
+		},
+		"should match trailing slash by default": {
+			path:     "/api/v1/",
+			wantCode: 200,
+			wantBody: "static",
+		},
+		"should not match duplicate slashes by default": {
+			path:     "//api//v1",
+			wantCode: 404,
+		},
+		"should not match dot elements by default": {
+			path:     "/api/./v1",
+			wantCode: 404,
+		},
+		"should not match different case by default": {
+			path:     "/API/V1",
+			wantCode: 404,
+		},
+		"should match duplicate slashes with clean path": {
+			opts:     []framework.RouterOption{framework.CleanPath()},
+			path:     "//api//v1",
+			wantCode: 200,
+			wantBody: "static",
+		},
+		"should match dot elements with clean path": {
+			opts:     []framework.RouterOption{framework.CleanPath()},
+			path:     "/api/./x/../v1",
+			wantCode: 200,
+			wantBody: "static",
+		},
+		"should keep trailing slash normalisation with clean path": {
+			opts:     []framework.RouterOption{framework.CleanPath()},
+			path:     "/api//v1/",
+			wantCode: 200,
+			wantBody: "static",
+		},
+		"should match different case with case insensitive": {
+			opts:     []framework.RouterOption{framework.CaseInsensitive()},
+			path:     "/API/V1",
+			wantCode: 200,
+			wantBody: "static",
+		},
+		"should keep case of variables with case insensitive": {
+			opts:       []framework.RouterOption{framework.CaseInsensitive()},
+			path:       "/api/users/John",
+			wantCode:   200,
+			wantBody:   "dynamic",
+			wantValues: map[string]string{"id": "John"},
+		},
+		"should not redirect canonical path": {
+			opts:     []framework.RouterOption{framework.RedirectCanonical(301)},
+			path:     "/api/v1",
+			wantCode: 200,
+			wantBody: "static",
+		},
+		"should not redirect trailing slash": {
+			opts:     []framework.RouterOption{framework.RedirectCanonical(301)},
+			path:     "/api/v1/",
+			wantCode: 200,
+			wantBody: "static",
+		},
+		"should redirect unclean path": {
+			opts:         []framework.RouterOption{framework.RedirectCanonical(301)},
+			path:         "//api/./v1/?q=1",
+			wantCode:     301,
+			wantLocation: "/api/v1/?q=1",
+		},
+		"should redirect to the case of the route": {
+			opts: []framework.RouterOption{
+				framework.CaseInsensitive(),
+				framework.RedirectCanonical(308),
+			},
+			path:         "/API/users/John",
+			wantCode:     308,
+			wantLocation: "/api/Users/John",
+		},
+		"should keep splat part when redirecting": {
+			opts: []framework.RouterOption{
+				framework.CaseInsensitive(),
+				framework.RedirectCanonical(308),
+			},
+			path:         "/FILES/A/../B.txt",
+			wantCode:     308,
+			wantLocation: "/files/B.txt",
+		},
+		"should not redirect unknown path": {
+			opts: []framework.RouterOption{
+				framework.CaseInsensitive(),
+				framework.RedirectCanonical(308),
+			},
+			path:     "/API/V2",
+			wantCode: 404,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rt := framework.NewRouter(nil, tt.opts...)
+			routes(rt)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.URL.Path, req.URL.RawQuery = tt.path, ""
+			if i := strings.IndexByte(tt.path, '?'); i >= 0 {
+				req.URL.Path, req.URL.RawQuery = tt.path[:i], tt.path[i+1:]
+			}
+
+			handler, values := rt.LookupRequest(req)
+			if tt.wantCode == 404 {
+				assert.Nil(t, handler)
+				return
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, tt.wantLocation, rec.Header().Get("Location"))
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, rec.Body.String())
+				assert.Equal(t, tt.wantValues, values)
+			}
+		})
+	}
+}
+
+func TestFramework_WithRouterOptions(t *testing.T) {
+	fw := framework.New().WithRouterOptions(
+		framework.CaseInsensitive(),
+		framework.RedirectCanonical(http.StatusPermanentRedirect),
+	)
+	fw.Post("/api/Items", helper.HandlerFactory(200, "items"))
+	fw.WithHost("api.example.com", func() {
+		fw.Get("/Status", helper.HandlerFactory(200, "status"))
+	})
+
+	tests := map[string]struct {
+		method       string
+		host         string
+		path         string
+		wantCode     int
+		wantLocation string
+	}{
+		"should serve canonical path": {
+			method:   http.MethodPost,
+			path:     "/api/Items",
+			wantCode: 200,
+		},
+		"should redirect to canonical path": {
+			method:       http.MethodPost,
+			path:         "/API//items",
+			wantCode:     308,
+			wantLocation: "/api/Items",
+		},
+		"should redirect host routes": {
+			method:       http.MethodGet,
+			host:         "api.example.com",
+			path:         "/status",
+			wantCode:     308,
+			wantLocation: "/Status",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", nil)
+			req.URL.Path = tt.path
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+
+			rec := httptest.NewRecorder()
+			fw.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, tt.wantLocation, rec.Header().Get("Location"))
+		})
+	}
+}
+
+func TestFramework_WithRouterOptions_Invalid(t *testing.T) {
+	assert.Panics(t, func() { framework.RedirectCanonical(http.StatusFound) })
+
+	assert.Panics(t, func() {
+		fw := framework.New()
+		fw.Get("/", helper.HandlerFactory(200, "root"))
+		fw.WithRouterOptions(framework.CleanPath())
+	})
+}
+
+func TestRouter_StrictSlash(t *testing.T) {
+	routes := func(rt *framework.Router) {
+		assert.NoError(t, rt.Handle("/", static))
+		assert.NoError(t, rt.Handle("/docs/", static1))
+		assert.NoError(t, rt.Handle("/docs/:page", dynamic))
+		assert.NoError(t, rt.Handle("/api/items", static2))
+		assert.NoError(t, rt.Handle("/dav/*", splat))
+		assert.NoError(t, rt.Handle("/users/:id", dynamic1))
+	}
+
+	tests := map[string]struct {
+		opts         []framework.RouterOption
+		path         string
+		wantCode     int
+		wantBody     string
+		wantLocation string
+	}{
+		"should match root": {
+			opts:     []framework.RouterOption{framework.StrictSlash()},
+			path:     "/",
+			wantCode: 200,
+			wantBody: "static",
+		},
+		"should match path with trailing slash": {
+			opts:     []framework.RouterOption{framework.StrictSlash()},
+			path:     "/docs/",
+			wantCode: 200,
+			wantBody: "static1",
+		},
+		"should not match path without trailing slash": {
+			opts:     []framework.RouterOption{framework.StrictSlash()},
+			path:     "/docs",
+			wantCode: 404,
+		},
+		"should not match path with extra trailing slash": {
+			opts:     []framework.RouterOption{framework.StrictSlash()},
+			path:     "/api/items/",
+			wantCode: 404,
+		},
+		"should not match trailing slash as empty variable": {
+			opts:     []framework.RouterOption{framework.StrictSlash()},
+			path:     "/users/",
+			wantCode: 404,
+		},
+		"should match splat with trailing slash": {
+			opts:     []framework.RouterOption{framework.StrictSlash()},
+			path:     "/dav/",
+			wantCode: 200,
+			wantBody: "splat",
+		},
+		"should redirect to path with trailing slash": {
+			opts:         []framework.RouterOption{framework.RedirectTrailingSlash()},
+			path:         "/docs?lang=en",
+			wantCode:     308,
+			wantLocation: "/docs/?lang=en",
+		},
+		"should redirect to path without trailing slash": {
+			opts:         []framework.RouterOption{framework.RedirectTrailingSlash()},
+			path:         "/api/items/",
+			wantCode:     308,
+			wantLocation: "/api/items",
+		},
+		"should redirect to canonical path with trailing slash": {
+			opts: []framework.RouterOption{
+				framework.RedirectTrailingSlash(),
+				framework.CaseInsensitive(),
+				framework.RedirectCanonical(301),
+			},
+			path:         "/DOCS",
+			wantCode:     308,
+			wantLocation: "/docs/",
+		},
+		"should keep trailing slash when redirecting to canonical path": {
+			opts: []framework.RouterOption{
+				framework.RedirectTrailingSlash(),
+				framework.RedirectCanonical(301),
+			},
+			path:         "//docs/",
+			wantCode:     301,
+			wantLocation: "/docs/",
+		},
+		"should not redirect unknown path": {
+			opts:     []framework.RouterOption{framework.RedirectTrailingSlash()},
+			path:     "/api/",
+			wantCode: 404,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rt := framework.NewRouter(nil, tt.opts...)
+			routes(rt)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.URL.Path = strings.SplitN(tt.path, "?", 2)[0]
+
+			handler, _ := rt.LookupRequest(req)
+			if tt.wantCode == 404 {
+				assert.Nil(t, handler)
+				return
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, tt.wantLocation, rec.Header().Get("Location"))
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRouter_RedirectTrailingSlash_Host(t *testing.T) {
+	tests := map[string]struct {
+		path         string
+		wantLocation string
+	}{
+		"should not redirect to another host": {
+			path:         "//evil.com",
+			wantLocation: "/evil.com/",
+		},
+		"should not redirect to another host without trailing slash": {
+			path:         "//evil.com/x/",
+			wantLocation: "/evil.com/x",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rt := framework.NewRouter(nil, framework.RedirectTrailingSlash())
+			assert.NoError(t, rt.Handle("/:a/:b/", dynamic))
+			assert.NoError(t, rt.Handle("/:a/:b/:c", dynamic1))
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+
+			handler, _ := rt.LookupRequest(req)
+			if !assert.NotNil(t, handler) {
+				return
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, 308, rec.Code)
+			assert.Equal(t, tt.wantLocation, rec.Header().Get("Location"))
+		})
+	}
+}
+
+func TestFramework_StrictSlash(t *testing.T) {
+	fw := framework.New().WithRouterOptions(framework.RedirectTrailingSlash())
+	fw.WithPrefix("/site", func() {
+		fw.Get("/", helper.HandlerFactory(200, "index"))
+		fw.Get("/about", helper.HandlerFactory(200, "about"))
+	})
+
+	tests := map[string]struct {
+		path         string
+		wantCode     int
+		wantBody     string
+		wantLocation string
+	}{
+		"should keep trailing slash of the route": {
+			path:     "/site/",
+			wantCode: 200,
+			wantBody: "index",
+		},
+		"should redirect to the route with trailing slash": {
+			path:         "/site",
+			wantCode:     308,
+			wantLocation: "/site/",
+		},
+		"should redirect to the route without trailing slash": {
+			path:         "/site/about/",
+			wantCode:     308,
+			wantLocation: "/site/about",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			fw.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, tt.wantLocation, rec.Header().Get("Location"))
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+
+	assert.Equal(t, "/site/", fw.Routes()[0].Pattern)
+}
+
+func TestRouter_RedirectCanonical_Escaped(t *testing.T) {
+	rt := framework.NewRouter(nil, framework.CaseInsensitive(), framework.RedirectCanonical(308))
+	assert.NoError(t, rt.Handle("/Café/:name", dynamic))
+
+	tests := map[string]struct {
+		path         string
+		wantCode     int
+		wantLocation string
+	}{
+		"should not redirect escaped canonical path": {
+			path:     "/Caf%C3%A9/a%2Fb",
+			wantCode: 200,
+		},
+		"should redirect keeping the escaped variable": {
+			path:         "/CAF%C3%89/a%2Fb",
+			wantCode:     308,
+			wantLocation: "/Caf%C3%A9/a%2Fb",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+
+			rec := httptest.NewRecorder()
+			rt.RouterHandler(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, tt.wantLocation, rec.Header().Get("Location"))
+		})
+	}
+}
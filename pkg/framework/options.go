@@ -0,0 +1,63 @@
+package framework
+
+import (
+	"net/http"
+
+	"github.com/snobb/susanin/pkg/middleware"
+)
+
+// RouteOption configures a single route or a group of routes declared with WithPrefix.
+// Group options are applied before the route ones, so the route options take precedence.
+type RouteOption func(*routeConfig)
+
+type routeConfig struct {
+	middlewares []middleware.Middleware
+	maxBodySize *int64
+	permissions []string
+	matchers    []Matcher
+}
+
+// Use attaches middlewares to a route or a group of routes. Unlike the Framework.Attach
+// middlewares they run after the route is matched. The middlewares are executed in the order
+// given and the group middlewares run before the route ones.
+func Use(middlewares ...middleware.Middleware) RouteOption {
+	return func(cfg *routeConfig) {
+		cfg.middlewares = append(cfg.middlewares, middlewares...)
+	}
+}
+
+// MaxBodySize overrides the Framework request body size limit for a route or a group of
+// routes. Zero or negative size disables the limit.
+func MaxBodySize(size int64) RouteOption {
+	return func(cfg *routeConfig) {
+		cfg.maxBodySize = &size
+	}
+}
+
+// newRouteConfig applies the group options followed by the route options.
+func newRouteConfig(groupOpts, routeOpts []RouteOption) *routeConfig {
+	cfg := &routeConfig{}
+
+	for _, opt := range groupOpts {
+		opt(cfg)
+	}
+
+	for _, opt := range routeOpts {
+		opt(cfg)
+	}
+
+	return cfg
+}
+
+// wrap wraps the handler with the route middlewares and limits.
+func (fw *Framework) wrap(pattern string, cfg *routeConfig, handler http.Handler) http.Handler {
+	handler = fw.authorize(cfg, handler)
+
+	for i := len(cfg.middlewares) - 1; i >= 0; i-- {
+		handler = cfg.middlewares[i](handler)
+	}
+
+	handler = fw.limitBody(cfg, handler)
+
+	return &routeHandler{pattern: pattern, next: handler}
+}
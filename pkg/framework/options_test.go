@@ -0,0 +1,114 @@
+package framework_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/framework"
+	"github.com/snobb/susanin/pkg/middleware"
+	"github.com/snobb/susanin/test/helper"
+)
+
+func TestFramework_Use(t *testing.T) {
+	trace := func(name string) middleware.Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Trace", name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	tests := map[string]struct {
+		path      string
+		wantTrace []string
+	}{
+		"should run no middlewares for the route outside of groups": {
+			path: "/plain",
+		},
+		"should run the route middlewares in order": {
+			path:      "/route",
+			wantTrace: []string{"r1", "r2"},
+		},
+		"should run the group middlewares before the route ones": {
+			path:      "/api/route",
+			wantTrace: []string{"g1", "r1"},
+		},
+		"should run the nested group middlewares": {
+			path:      "/api/v1/route",
+			wantTrace: []string{"g1", "g2", "r1"},
+		},
+		"should not leak the group middlewares after the group": {
+			path:      "/api/after",
+			wantTrace: []string{"g1"},
+		},
+	}
+
+	fw := framework.New()
+	fw.Get("/plain", helper.HandlerFactory(200, "ok"))
+	fw.Get("/route", helper.HandlerFactory(200, "ok"), framework.Use(trace("r1"), trace("r2")))
+	fw.WithPrefix("/api", func() {
+		fw.Get("/route", helper.HandlerFactory(200, "ok"), framework.Use(trace("r1")))
+		fw.WithPrefix("/v1", func() {
+			fw.Get("/route", helper.HandlerFactory(200, "ok"), framework.Use(trace("r1")))
+		}, framework.Use(trace("g2")))
+		fw.Get("/after", helper.HandlerFactory(200, "ok"))
+	}, framework.Use(trace("g1")))
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+
+			fw.ServeHTTP(rr, req)
+
+			assert.Equal(t, 200, rr.Code)
+			assert.Equal(t, tt.wantTrace, rr.Header()["X-Trace"])
+		})
+	}
+}
+
+func TestFramework_GetPattern(t *testing.T) {
+	tests := map[string]struct {
+		path        string
+		wantPattern string
+	}{
+		"should store the static route pattern": {
+			path:        "/api/users",
+			wantPattern: "/api/users",
+		},
+		"should store the variable route pattern": {
+			path:        "/api/users/42",
+			wantPattern: "/api/users/:id",
+		},
+		"should store the splat route pattern": {
+			path:        "/static/css/main.css",
+			wantPattern: "/static/*",
+		},
+	}
+
+	fw := framework.New()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pattern, ok := framework.GetPattern(r.Context())
+		assert.True(t, ok)
+		_, _ = w.Write([]byte(pattern))
+	})
+
+	fw.Get("/static/*", handler)
+	fw.WithPrefix("/api", func() {
+		fw.Get("/users", handler)
+		fw.Get("/users/:id", handler)
+	})
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			fw.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.wantPattern, rr.Body.String())
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package framework
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// paramsSize is the capacity of the pooled params and of the params stored along with the
+// request context without an extra allocation.
+const paramsSize = 8
+
+// Param is a pattern value.
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Params are the pattern values in the order they were matched, e.g. the host values followed
+// by the path ones.
+type Params []Param
+
+// Get returns the value of the key. The latest value is returned if the key was matched
+// several times.
+func (ps Params) Get(key string) (string, bool) {
+	for i := len(ps) - 1; i >= 0; i-- {
+		if ps[i].Key == key {
+			return ps[i].Value, true
+		}
+	}
+
+	return "", false
+}
+
+// Map returns the values as a map or nil if there are none.
+func (ps Params) Map() map[string]string {
+	if len(ps) == 0 {
+		return nil
+	}
+
+	values := make(map[string]string, len(ps))
+	for _, p := range ps {
+		values[p.Key] = p.Value
+	}
+
+	return values
+}
+
+var paramsPool = sync.Pool{
+	New: func() interface{} {
+		ps := make(Params, 0, paramsSize)
+		return &ps
+	},
+}
+
+func acquireParams() *Params {
+	return paramsPool.Get().(*Params)
+}
+
+func releaseParams(ps *Params) {
+	*ps = (*ps)[:0]
+	paramsPool.Put(ps)
+}
+
+// paramsContext carries the params and the pattern of the request. The params are copied into
+// it, so the pooled ones can be reused as soon as the handler returns even if the context
+// outlives it, e.g. in the timeout middleware.
+type paramsContext struct {
+	context.Context
+	params  Params
+	pattern string
+	buf     [paramsSize]Param
+}
+
+func (c *paramsContext) Value(key interface{}) interface{} {
+	switch key.(type) {
+	case valuesKey:
+		if len(c.params) != 0 {
+			return c
+		}
+
+	case patternKey:
+		if c.pattern != "" {
+			return c.pattern
+		}
+	}
+
+	return c.Context.Value(key)
+}
+
+// withRoute stores the params and the route pattern in the request context at once merging the
+// params with the ones matched earlier, e.g. by another router.
+func withRoute(r *http.Request, ps Params, pattern string) *http.Request {
+	if len(ps) == 0 && pattern == "" {
+		return r
+	}
+
+	prev, _ := GetParams(r.Context())
+	if len(ps) == 0 {
+		prev = nil // inherited from the parent context
+	}
+
+	c := &paramsContext{Context: r.Context(), pattern: pattern}
+	if n := len(prev) + len(ps); n <= paramsSize {
+		c.params = c.buf[:0]
+	} else {
+		c.params = make(Params, 0, n)
+	}
+
+	c.params = append(append(c.params, prev...), ps...)
+
+	return r.WithContext(c)
+}
+
+// routeHandler is the handler of a Framework route. The pattern is stored in the request context
+// along with the params, so it does not cost another context.
+type routeHandler struct {
+	pattern string
+	next    http.Handler
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (rh *routeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rh.next.ServeHTTP(w, withRoute(r, nil, rh.pattern))
+}
+
+// serveRoute serves the request with the handler storing the params in the request context.
+func serveRoute(w http.ResponseWriter, r *http.Request, handler http.Handler, ps Params) {
+	if rh, ok := handler.(*routeHandler); ok {
+		rh.next.ServeHTTP(w, withRoute(r, ps, rh.pattern))
+		return
+	}
+
+	handler.ServeHTTP(w, withRoute(r, ps, ""))
+}
+
+// GetParams gets the match pattern values from the http.Request context. Unlike GetValues it
+// does not allocate. The params must not be modified.
+func GetParams(ctx context.Context) (Params, bool) {
+	c, ok := ctx.Value(valuesKey{}).(*paramsContext)
+	if !ok {
+		return nil, false
+	}
+
+	return c.params, true
+}
@@ -0,0 +1,203 @@
+package framework
+
+import (
+	"sort"
+	"strings"
+)
+
+// radixNode is a node of the compressed radix tree of the constant path elements following a
+// link. The tree is compiled from the nextConst maps of the links. The runs of the links
+// having a single constant element and nothing else to match, e.g. "api/v1" in "/api/v1/:id",
+// are compressed into a single label, so they are matched without visiting every link.
+type radixNode struct {
+	label    string       // constant keys joined with slashes
+	indices  string       // first bytes of the children labels
+	children []*radixNode // in the order of indices
+	marks    []radixMark  // ends of the path elements within the label ordered by offset
+}
+
+// radixMark is the end of a path element within a label.
+type radixMark struct {
+	off  int // offset of the end of the element within the label
+	link *chainLink
+}
+
+// radixPos is a position in the tree, i.e. a node and an offset within its label.
+type radixPos struct {
+	node *radixNode
+	off  int
+}
+
+// advance matches the key from the position and returns the position after it.
+func (pos radixPos) advance(key string) (radixPos, bool) {
+	n, off := pos.node, pos.off
+
+	for {
+		rest := n.label[off:]
+		if len(key) <= len(rest) {
+			if rest[:len(key)] != key {
+				return radixPos{}, false
+			}
+
+			return radixPos{node: n, off: off + len(key)}, true
+		}
+
+		if key[:len(rest)] != rest {
+			return radixPos{}, false
+		}
+
+		key = key[len(rest):]
+
+		i := strings.IndexByte(n.indices, key[0])
+		if i == -1 {
+			return radixPos{}, false
+		}
+
+		n, off = n.children[i], 0
+	}
+}
+
+// link returns the link of the path element ending at the position or nil if no element ends
+// there.
+func (pos radixPos) link() *chainLink {
+	for _, m := range pos.node.marks {
+		if m.off == pos.off {
+			return m.link
+		}
+
+		if m.off > pos.off {
+			break
+		}
+	}
+
+	return nil
+}
+
+// passThrough reports if the link has a single constant element following it and nothing else
+// to match, so it can be compressed into the label of the parent.
+func (cl *chainLink) passThrough() bool {
+	return len(cl.nextConst) == 1 && len(cl.routes) == 0 && cl.nextVar == nil &&
+		len(cl.nextMixed) == 0 && cl.nextMulti == nil && cl.nextSplat == nil
+}
+
+// compile builds the radix tree of the constant path elements following the link. The tree
+// must be rebuilt after the link or any link compressed into it changes.
+func (cl *chainLink) compile() {
+	cl.pass = cl.passThrough()
+
+	if len(cl.nextConst) == 0 {
+		cl.consts = nil
+		return
+	}
+
+	keys := make([]string, 0, len(cl.nextConst))
+	for key := range cl.nextConst {
+		keys = append(keys, key)
+	}
+
+	// the tree does not depend on the order of insertion, the sorting keeps its children
+	// ordered as well
+	sort.Strings(keys)
+
+	root := &radixNode{}
+
+	for _, key := range keys {
+		link := cl.nextConst[key]
+		marks := []radixMark{{off: len(key), link: link}}
+
+		for link.passThrough() {
+			for next, nextLink := range link.nextConst {
+				key += "/" + next
+				link = nextLink
+			}
+
+			marks = append(marks, radixMark{off: len(key), link: link})
+		}
+
+		root.insert(key, marks)
+	}
+
+	cl.consts = root
+}
+
+// insert adds the key along with the marks of its path elements.
+func (n *radixNode) insert(key string, marks []radixMark) {
+	for {
+		// the length of the common prefix of the key and the label
+		i := 0
+		for i < len(key) && i < len(n.label) && key[i] == n.label[i] {
+			i++
+		}
+
+		if i < len(n.label) {
+			n.split(i)
+		}
+
+		// the marks within the label
+		for len(marks) > 0 && marks[0].off <= i {
+			n.addMark(marks[0])
+			marks = marks[1:]
+		}
+
+		key = key[i:]
+		if key == "" {
+			return
+		}
+
+		for j := range marks {
+			marks[j].off -= i
+		}
+
+		if idx := strings.IndexByte(n.indices, key[0]); idx != -1 {
+			n = n.children[idx]
+			continue
+		}
+
+		n.addChild(&radixNode{label: key, marks: marks})
+		return
+	}
+}
+
+// split splits the label at the offset moving the rest of it into a new child.
+func (n *radixNode) split(off int) {
+	child := &radixNode{
+		label:    n.label[off:],
+		indices:  n.indices,
+		children: n.children,
+	}
+
+	var marks []radixMark
+	for _, m := range n.marks {
+		if m.off <= off {
+			marks = append(marks, m)
+		} else {
+			child.marks = append(child.marks, radixMark{off: m.off - off, link: m.link})
+		}
+	}
+
+	n.label = n.label[:off]
+	n.marks = marks
+	n.indices = ""
+	n.children = nil
+	n.addChild(child)
+}
+
+// addChild adds the child keeping the children ordered by the first byte of their labels.
+func (n *radixNode) addChild(child *radixNode) {
+	c := child.label[0]
+	idx := sort.Search(len(n.indices), func(i int) bool { return n.indices[i] >= c })
+
+	n.indices = n.indices[:idx] + string(c) + n.indices[idx:]
+	n.children = append(n.children, nil)
+	copy(n.children[idx+1:], n.children[idx:])
+	n.children[idx] = child
+}
+
+// addMark adds the mark keeping the marks ordered by offset.
+func (n *radixNode) addMark(m radixMark) {
+	idx := sort.Search(len(n.marks), func(i int) bool { return n.marks[i].off >= m.off })
+
+	n.marks = append(n.marks, radixMark{})
+	copy(n.marks[idx+1:], n.marks[idx:])
+	n.marks[idx] = m
+}
@@ -0,0 +1,172 @@
+package framework_test
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/framework"
+	"github.com/snobb/susanin/test/helper"
+)
+
+var differentialRoutes = []string{
+	"/",
+	"/api",
+	"/api/v1/status",
+	"/api/v1/users",
+	"/api/v1/users/:id",
+	"/api/v1/users/:id/posts/:post",
+	"/api/v1/user-groups/:group",
+	"/api/v2/status",
+	"/api/:version/items",
+	"/app",
+	"/apple/pie",
+	"/Café/menu",
+	"/docs/",
+	"/docs/:page+",
+	"/files/:name.:ext",
+	"/files/:name.json",
+	"/files/:id",
+	"/repos/:owner/:repo/blob/:ref+/raw",
+	"/repos/:owner/:repo/blob/latest/raw",
+	"/reports/:year/:month?",
+	"/static/*",
+	"/static/css/site.css",
+	"/v:major.:minor/status",
+	"/a/b/c/d/e",
+	"/a/b/x",
+	"/*",
+}
+
+var differentialOptions = map[string][]framework.RouterOption{
+	"default":          nil,
+	"clean path":       {framework.CleanPath()},
+	"case insensitive": {framework.CaseInsensitive(), framework.RedirectCanonical(308)},
+	"strict slash":     {framework.StrictSlash()},
+	"redirect slash":   {framework.RedirectTrailingSlash(), framework.RedirectCanonical(301)},
+}
+
+func differentialRouter(t testing.TB, opts []framework.RouterOption) *framework.Router {
+	rt := framework.NewRouter(nil, opts...)
+	for _, p := range differentialRoutes {
+		if err := rt.Handle(p, helper.HandlerFactory(200, p)); err != nil {
+			t.Fatal(p, err)
+		}
+	}
+
+	return rt
+}
+
+// assertSameLookup checks the radix tree lookup of the path against the map based one.
+func assertSameLookup(t testing.TB, rt *framework.Router, path string) {
+	u, err := url.Parse(path)
+	if err != nil || u.Path == "" || u.Path[0] != '/' || u.Host != "" {
+		return
+	}
+
+	req := &http.Request{Method: http.MethodGet, URL: u, Header: http.Header{}}
+
+	handler, values := rt.LookupRequest(req)
+	wantHandler, wantValues := rt.LegacyLookup(req)
+
+	assert.Equal(t, wantValues, values, path)
+	if wantHandler == nil || handler == nil {
+		assert.Equal(t, wantHandler == nil, handler == nil, path)
+		return
+	}
+
+	rec, wantRec := httptest.NewRecorder(), httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	wantHandler.ServeHTTP(wantRec, req)
+
+	assert.Equal(t, wantRec.Code, rec.Code, path)
+	assert.Equal(t, wantRec.Header().Get("Location"), rec.Header().Get("Location"), path)
+	assert.Equal(t, wantRec.Body.String(), rec.Body.String(), path)
+}
+
+// randomPath builds a path from the elements of the routes mixed with random ones.
+func randomPath(rnd *rand.Rand) string {
+	var elems []string
+	for _, p := range differentialRoutes {
+		elems = append(elems, strings.Split(p, "/")...)
+	}
+
+	elems = append(elems, "", ".", "..", "x", "42", "a.tar.gz", "v1.2", "Caf%C3%A9",
+		"a%2Fb", "%41PI", "raw", "latest", "data.json", "user-groups")
+
+	var sb strings.Builder
+	for n := rnd.Intn(8); n >= 0; n-- {
+		elem := elems[rnd.Intn(len(elems))]
+		if rnd.Intn(4) == 0 {
+			elem = strings.ToUpper(elem)
+		}
+
+		sb.WriteString("/")
+		sb.WriteString(elem)
+	}
+
+	if rnd.Intn(4) == 0 {
+		sb.WriteString("/")
+	}
+
+	return sb.String()
+}
+
+func TestRouter_Radix_Differential(t *testing.T) {
+	for name, opts := range differentialOptions {
+		t.Run(name, func(t *testing.T) {
+			rt := differentialRouter(t, opts)
+			rnd := rand.New(rand.NewSource(1))
+
+			for _, p := range differentialRoutes {
+				assertSameLookup(t, rt, p)
+			}
+
+			for i := 0; i < 5000; i++ {
+				assertSameLookup(t, rt, randomPath(rnd))
+			}
+		})
+	}
+}
+
+func TestRouter_Radix_Registration(t *testing.T) {
+	// the trees are rebuilt as the routes are added, so every prefix of the routes is checked
+	rnd := rand.New(rand.NewSource(2))
+
+	for n := 1; n <= len(differentialRoutes); n++ {
+		rt := framework.NewRouter(nil)
+		for _, p := range differentialRoutes[:n] {
+			assert.NoError(t, rt.Handle(p, helper.HandlerFactory(200, p)))
+		}
+
+		for i := 0; i < 200; i++ {
+			assertSameLookup(t, rt, randomPath(rnd))
+		}
+	}
+}
+
+func FuzzRouter_Radix(f *testing.F) {
+	for _, p := range differentialRoutes {
+		f.Add(p)
+	}
+
+	f.Add("//api//v1/./users/42/")
+	f.Add("/API/V1/USERS/42")
+	f.Add("/repos/a/b/blob/x/y/z/raw")
+
+	routers := make([]*framework.Router, 0, len(differentialOptions))
+	for _, opts := range differentialOptions {
+		routers = append(routers, differentialRouter(f, opts))
+	}
+
+	f.Fuzz(func(t *testing.T, path string) {
+		for _, rt := range routers {
+			assertSameLookup(t, rt, path)
+		}
+	})
+}
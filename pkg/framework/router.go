@@ -9,10 +9,13 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"net/url"
+	"sort"
 	"strings"
 )
 
 type valuesKey struct{}
+type patternKey struct{}
 
 const rootLink = "#ROOT#"
 
@@ -20,14 +23,34 @@ const rootLink = "#ROOT#"
 type Router struct {
 	root            *chainLink
 	notFoundHandler http.Handler
+	cleanPath       bool
+	caseInsensitive bool
+	redirectCode    int
+	strictSlash     bool
+	redirectSlash   bool
+}
+
+// Matcher is a request predicate evaluated after the path of a route is matched, e.g. on a
+// header value.
+type Matcher func(r *http.Request) bool
+
+// route is a handler registered for a path along with its matchers.
+type route struct {
+	handler  http.Handler
+	matchers []Matcher
 }
 
 type chainLink struct {
 	name      string
 	nextConst map[string]*chainLink
 	nextVar   *chainLink
+	nextMixed []*chainLink // ordered by precedence
+	nextMulti *chainLink
 	nextSplat *chainLink
-	handler   http.Handler
+	parts     []segmentPart // parts of the mixed path element
+	routes    []*route      // ordered by precedence
+	consts    *radixNode    // compiled nextConst
+	pass      bool          // compressed into the radix tree of the parent
 }
 
 func newChainLink(token string) *chainLink {
@@ -42,15 +65,48 @@ func newChainLink(token string) *chainLink {
 }
 
 // NewRouter creates a new Router instance
-func NewRouter(notFoundHandler http.Handler) *Router {
-	return &Router{
+func NewRouter(notFoundHandler http.Handler, opts ...RouterOption) *Router {
+	rt := &Router{
 		root:            newChainLink(rootLink),
 		notFoundHandler: notFoundHandler,
 	}
+
+	for _, opt := range opts {
+		opt(rt)
+	}
+
+	return rt
+}
+
+// Handle add a route and a handler. Several handlers can be added for the same path with
+// different matchers. The handlers with more matchers are tried first and the handlers with the
+// same number of matchers are tried in the order they were added.
+// The path elements are not escaped, e.g. "/café" matches the "/caf%C3%A9" request path.
+// A path element can combine literals and variables, e.g. "/files/:name.:ext" or
+// "/v:major.:minor". Such elements are tried after the constant ones and before the plain
+// variable, the ones with longer literals first.
+// The trailing variables can be optional, e.g. "/reports/:year/:month?" matches with or without
+// the month. A variable ending with "+" matches one or more path elements anywhere in the path,
+// e.g. "/repos/:owner/:repo/blob/:ref+/raw", and is tried after the other patterns of the level,
+// taking as many elements as possible. The value of such variable is the elements joined with a
+// slash.
+func (rt *Router) Handle(path string, handler http.Handler, matchers ...Matcher) error {
+	paths, err := expandOptional(path)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range paths {
+		if err := rt.handle(p, handler, matchers); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-// Handle add a route and a handler
-func (rt *Router) Handle(path string, handler http.Handler) (err error) {
+// handle adds a route without optional elements.
+func (rt *Router) handle(path string, handler http.Handler, matchers []Matcher) error {
 	splatIdx := strings.IndexRune(path, '*')
 
 	if splatIdx != -1 && splatIdx != len(path)-1 {
@@ -61,8 +117,8 @@ func (rt *Router) Handle(path string, handler http.Handler) (err error) {
 		path = path[1:]
 	}
 
-	// clear trailing slash so that it matches /path and /path/
-	if len(path) > 0 && path[len(path)-1] == '/' {
+	// clear trailing slash so that it matches /path and /path/ unless the slash is strict
+	if !rt.strictSlash && len(path) > 0 && path[len(path)-1] == '/' {
 		path = path[:len(path)-1]
 	}
 
@@ -70,9 +126,37 @@ func (rt *Router) Handle(path string, handler http.Handler) (err error) {
 
 	cur := rt.root
 
+	// the radix trees of the links on the path are rebuilt, the deepest first, since the links
+	// compressed into the trees of their parents might have changed
+	links := make([]*chainLink, 0, len(tokens)+1)
+	defer func() {
+		for i := len(links) - 1; i >= 0; i-- {
+			links[i].compile()
+		}
+	}()
+
 	for _, token := range tokens {
+		links = append(links, cur)
+
 		switch {
-		case len(token) > 0 && token[0] == ':': // variable
+		case isMulti(token): // one or more elements, e.g. :ref+
+			if cur.nextMulti == nil {
+				cur.nextMulti = newChainLink(token[:len(token)-1])
+			} else if token[1:len(token)-1] != cur.nextMulti.name {
+				return errors.New("conflict: duplicate pattern at the same level")
+			}
+
+			cur = cur.nextMulti
+
+		case isMixed(token): // literals and variables, e.g. :name.:ext
+			next, err := cur.addMixed(token)
+			if err != nil {
+				return err
+			}
+
+			cur = next
+
+		case isVar(token): // variable
 			if cur.nextVar == nil {
 				cur.nextVar = newChainLink(token)
 			} else if token[1:] != cur.nextVar.name {
@@ -82,21 +166,27 @@ func (rt *Router) Handle(path string, handler http.Handler) (err error) {
 			cur = cur.nextVar
 
 		case token == "*": // splat
-			cur.nextSplat = newChainLink(token)
+			if cur.nextSplat == nil {
+				cur.nextSplat = newChainLink(token)
+			}
+
 			cur = cur.nextSplat
 
 		default:
+			token = literal(token)
+			key := rt.constKey(token)
+
 			var next *chainLink
 			if cur.nextConst == nil {
 				cur.nextConst = make(map[string]*chainLink)
-				next = newChainLink(token)
-				cur.nextConst[token] = next
+				next = &chainLink{name: token}
+				cur.nextConst[key] = next
 			} else {
-				if found, ok := cur.nextConst[token]; ok {
+				if found, ok := cur.nextConst[key]; ok {
 					next = found
 				} else {
-					next = newChainLink(token)
-					cur.nextConst[token] = next
+					next = &chainLink{name: token}
+					cur.nextConst[key] = next
 				}
 			}
 
@@ -104,101 +194,451 @@ func (rt *Router) Handle(path string, handler http.Handler) (err error) {
 		}
 	}
 
-	if cur.handler != nil {
-		return errors.New("handler already exists")
+	links = append(links, cur)
+
+	if len(matchers) == 0 {
+		for _, rte := range cur.routes {
+			if len(rte.matchers) == 0 {
+				return errors.New("handler already exists")
+			}
+		}
 	}
 
-	cur.handler = handler
+	idx := sort.Search(len(cur.routes), func(i int) bool {
+		return len(cur.routes[i].matchers) < len(matchers)
+	})
+
+	cur.routes = append(cur.routes, nil)
+	copy(cur.routes[idx+1:], cur.routes[idx:])
+	cur.routes[idx] = &route{handler: handler, matchers: matchers}
+
+	return nil
+}
+
+// match returns the handler of the first route matching the request or nil. Only the routes
+// without matchers match a nil request.
+func (cl *chainLink) match(r *http.Request) http.Handler {
+	for _, rte := range cl.routes {
+		matched := true
+
+		for _, m := range rte.matchers {
+			if r == nil || !m(r) {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return rte.handler
+		}
+	}
 
 	return nil
 }
 
 // Lookup for a handler in the path, a handler and pattern values is returned.
+// The path is the escaped one, e.g. r.URL.EscapedPath(), and the values are unescaped.
 // If handler is not found the function returns NotFoundHandler configured for the router (can be
 // nil).
+// Only the handlers added without matchers are considered.
 func (rt *Router) Lookup(path string) (http.Handler, map[string]string) {
+	return rt.lookupValues(path, nil)
+}
+
+// LookupRequest is like Lookup but it also evaluates the route matchers against the request.
+// The escaped request path is matched, so the escaped slashes do not split the path elements.
+func (rt *Router) LookupRequest(r *http.Request) (http.Handler, map[string]string) {
+	return rt.lookupValues(r.URL.EscapedPath(), r)
+}
+
+func (rt *Router) lookupValues(path string, r *http.Request) (http.Handler, map[string]string) {
+	ps := acquireParams()
+	defer releaseParams(ps)
+
+	handler := rt.lookup(path, r, ps)
+	if handler == nil {
+		return rt.notFoundHandler, nil
+	}
+
+	return handler, ps.Map()
+}
+
+// LookupParams is like LookupRequest but it appends the values to the params instead of
+// allocating a map, so the lookup of the constant and plain variable routes does not allocate
+// if the params have enough capacity. The params are left intact if the handler is not found.
+func (rt *Router) LookupParams(r *http.Request, ps *Params) http.Handler {
+	handler := rt.lookup(r.URL.EscapedPath(), r, ps)
+	if handler == nil {
+		return rt.notFoundHandler
+	}
+
+	return handler
+}
+
+// lookup looks for a handler in the path appending the values to the params. Nil handler is
+// returned if the path is not found or none of the path handlers matches the request. If the
+// router redirects to the canonical path or the other trailing slash form of the path, the
+// redirect handler is returned.
+func (rt *Router) lookup(path string, r *http.Request, ps *Params) http.Handler {
+	n := len(*ps)
+
+	handler, target := rt.find(path, r, ps)
+
+	if handler == nil && rt.redirectSlash && path != "/" {
+		alt := path + "/"
+		if path[len(path)-1] == '/' {
+			alt = path[:len(path)-1]
+		}
+
+		*ps = (*ps)[:n]
+		if handler, target = rt.find(alt, r, ps); handler != nil {
+			if target == "" {
+				target = alt
+			}
+
+			*ps = (*ps)[:n]
+			return redirect(target, http.StatusPermanentRedirect)
+		}
+	}
+
+	if handler == nil || target != "" {
+		*ps = (*ps)[:n]
+	}
+
+	if target != "" {
+		return redirect(target, rt.redirectCode)
+	}
+
+	return handler
+}
+
+// find looks for a handler in the escaped path. The path elements are unescaped before
+// matching, so the escaped slashes are kept in the variable values. If the router redirects to
+// the canonical path and the path differs from it, the canonical path is returned as well.
+func (rt *Router) find(path string, r *http.Request, ps *Params) (http.Handler, string) {
+	orig := path
+	if rt.cleanPath {
+		path = cleanPath(path)
+	}
+
+	trailingSlash := false
 	if path[0] == '/' {
 		path = path[1:]
 	}
 
-	// clear trailing slash so that it matches /path and /path/
-	if len(path) > 0 && path[len(path)-1] == '/' {
+	// clear trailing slash so that it matches /path and /path/ unless the slash is strict
+	if !rt.strictSlash && len(path) > 0 && path[len(path)-1] == '/' {
 		path = path[:len(path)-1]
+		trailingSlash = true
 	}
 
-	tokens := strings.Split(path, "/")
+	st := walkState{path: path, r: r, params: ps}
 
-	cur := rt.root
-	var splatHandler http.Handler
-	var values map[string]string
-	hasSplat, found := false, false
+	if handler := rt.walk(rt.root, 0, &st); handler != nil {
+		return handler, rt.canonical(orig, path, st.edits, trailingSlash)
+	}
+
+	return nil, ""
+}
+
+// walkState is the state of the path matching. The path elements are addressed by their
+// offsets in the path, so the path is never split.
+type walkState struct {
+	path   string
+	r      *http.Request
+	params *Params
+	edits  []edit // elements in the case of the routes if it differs from the path
+}
+
+// edit replaces the path element starting at the offset when building the canonical path.
+type edit struct {
+	start, end int
+	s          string
+}
+
+// setCanonical sets the canonical form of the element and drops the edits of the elements
+// after it, which are left from the paths tried earlier.
+func (st *walkState) setCanonical(start, end int, s string) {
+	st.resetCanonical(start, len(st.path)+1)
+
+	if s != st.path[start:end] {
+		st.edits = append(st.edits, edit{start: start, end: end, s: s})
+	}
+}
+
+// resetCanonical drops the edits of the elements starting in the range, so they are kept as
+// is, e.g. the splat part of the path.
+func (st *walkState) resetCanonical(from, to int) {
+	n := 0
+	for _, e := range st.edits {
+		if e.start < from || e.start >= to {
+			st.edits[n] = e
+			n++
+		}
+	}
+
+	st.edits = st.edits[:n]
+}
+
+// walk matches the path elements from the offset i starting with the link. The constant
+// elements are tried first, then the mixed ones and the variable. If the rest of the path does
+// not match, the deepest multi element variable and then the deepest splat on the way are
+// tried.
+func (rt *Router) walk(cur *chainLink, i int, st *walkState) http.Handler {
+	path := st.path
+	canonical := rt.redirectCode != 0
+
+	var splatLink, multiLink *chainLink
+	var splatIdx, multiIdx int
+	var multiParams int // number of params when the multi element variable was found
+	var pos radixPos    // position in the radix tree if the link is compressed into it
+	found := true
+
+	for i <= len(path) {
+		end := strings.IndexByte(path[i:], '/')
+		if end == -1 {
+			end = len(path)
+		} else {
+			end += i
+		}
+
+		token := path[i:end]
 
-	for _, token := range tokens {
 		if cur.nextSplat != nil {
-			splatHandler = cur.nextSplat.handler
-			hasSplat = true
+			splatLink = cur.nextSplat
+			splatIdx = i
+		}
+
+		if cur.nextMulti != nil {
+			multiLink = cur.nextMulti
+			multiIdx = i
+			multiParams = len(*st.params)
 		}
 
+		// the trailing slash is not a variable value in the strict mode
+		trailing := rt.strictSlash && token == "" && i > 0 && end == len(path)
+		value := unescape(token)
+
 		found = false
-		if next, ok := cur.nextConst[token]; ok {
+		if next, ok := cur.matchConst(&pos, rt.constKey(value)); ok {
 			cur = next
 			found = true
-		} else if cur.nextVar != nil {
-			cur = cur.nextVar
-			if values == nil {
-				values = make(map[string]string)
+
+			if canonical {
+				s := token
+				if next.name != value {
+					s = url.PathEscape(next.name)
+				}
+
+				st.setCanonical(i, end, s)
 			}
-			values[cur.name] = token
+		} else if next, captures := cur.matchMixed(value, rt.caseInsensitive); next != nil {
+			cur = next
 			found = true
+
+			j := 0
+			for _, p := range next.parts {
+				if p.name != "" {
+					*st.params = append(*st.params, Param{Key: p.name, Value: captures[j]})
+					j++
+				}
+			}
+
+			if canonical {
+				s := token
+				if name := literalCase(next.parts, captures); name != value {
+					s = url.PathEscape(name)
+				}
+
+				st.setCanonical(i, end, s)
+			}
+		} else if cur.nextVar != nil && !trailing {
+			cur = cur.nextVar
+			*st.params = append(*st.params, Param{Key: cur.name, Value: value})
+			found = true
+
+			if canonical {
+				st.setCanonical(i, end, token)
+			}
 		}
 
 		if !found {
 			break
 		}
+
+		i = end + 1
+	}
+
+	if found {
+		if handler := cur.match(st.r); handler != nil {
+			return handler
+		}
+	}
+
+	// the params of the failed branch are dropped before the multi element variable
+	if multiLink != nil {
+		*st.params = (*st.params)[:multiParams]
+		if handler := rt.walkMulti(multiLink, multiIdx, st); handler != nil {
+			return handler
+		}
+	}
+
+	if splatLink != nil {
+		if handler := splatLink.match(st.r); handler != nil {
+			// the splat part of the path is kept as is
+			st.resetCanonical(splatIdx, len(path)+1)
+			return handler
+		}
+	}
+
+	return nil
+}
+
+// matchConst matches the constant path element key following the link. The position is
+// updated to continue from the compressed links.
+func (cl *chainLink) matchConst(pos *radixPos, key string) (*chainLink, bool) {
+	var ok bool
+
+	p := *pos
+	switch {
+	case strings.IndexByte(key, '/') != -1:
+		// an unescaped slash, the constant keys never have it but the labels do
+
+	case p.node != nil:
+		p, ok = p.advance("/")
+
+	case cl.consts != nil:
+		p, ok = radixPos{node: cl.consts}, true
+	}
+
+	if ok {
+		p, ok = p.advance(key)
+	}
+
+	var next *chainLink
+	if ok {
+		next = p.link()
+	}
+
+	*pos = radixPos{}
+	if next == nil {
+		return nil, false
+	}
+
+	if next.pass {
+		*pos = p
+	}
+
+	return next, true
+}
+
+// walkMulti matches the multi element variable from the offset i taking as many non-empty
+// elements as possible.
+func (rt *Router) walkMulti(link *chainLink, i int, st *walkState) http.Handler {
+	path := st.path
+	n := len(*st.params)
+
+	// the variable ends before the first empty element
+	end := i
+	for start := i; start <= len(path); {
+		e := strings.IndexByte(path[start:], '/')
+		if e == -1 {
+			e = len(path)
+		} else {
+			e += start
+		}
+
+		if e == start {
+			break
+		}
+
+		end, start = e, e+1
 	}
 
-	if found && cur.handler != nil {
-		return cur.handler, values
+	for j := end; j > i; {
+		*st.params = (*st.params)[:n]
+
+		var handler http.Handler
+		if j == len(path) {
+			handler = link.match(st.r)
+		} else {
+			handler = rt.walk(link, j+1, st)
+		}
+
+		if handler != nil {
+			*st.params = append(*st.params, Param{Key: link.name, Value: unescape(path[i:j])})
+			st.resetCanonical(i, j)
+
+			return handler
+		}
+
+		k := strings.LastIndexByte(path[i:j], '/')
+		if k == -1 {
+			break
+		}
+
+		j = i + k
 	}
 
-	if hasSplat {
-		return splatHandler, values
+	*st.params = (*st.params)[:n]
+	return nil
+}
+
+// unescape decodes the escaped path element. The element is returned as is if it is not
+// escaped correctly.
+func unescape(token string) string {
+	if strings.IndexByte(token, '%') < 0 {
+		return token
+	}
+
+	value, err := url.PathUnescape(token)
+	if err != nil {
+		return token
 	}
 
-	return rt.notFoundHandler, nil
+	return value
 }
 
 // RouterHandler is a http.HandlerFunc router that dispatches the request
 // based on saved routes and handlers
 func (rt *Router) RouterHandler(w http.ResponseWriter, r *http.Request) {
-	uri := r.URL.Path
+	ps := acquireParams()
+	defer releaseParams(ps)
 
-	handler, values := rt.Lookup(uri)
-	if handler == nil {
-		// set default NotFoundHandler
-		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			returnError(w, "Endpoint is not found", 404)
-		})
-	}
+	rt.serve(w, r, ps)
+}
 
-	if len(values) > 0 {
-		ctx := r.Context()
-		ctx = context.WithValue(ctx, valuesKey{}, values)
-		r = r.WithContext(ctx)
+// serve dispatches the request appending the values to the params.
+func (rt *Router) serve(w http.ResponseWriter, r *http.Request, ps *Params) {
+	handler := rt.LookupParams(r, ps)
+	if handler == nil {
+		handler = notFoundHandler
 	}
 
-	handler.ServeHTTP(w, r)
+	serveRoute(w, r, handler, *ps)
 }
 
-// GetValues gets the match pattern values from the http.Request context
+// notFoundHandler is the default NotFoundHandler.
+var notFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	returnError(w, "Endpoint is not found", 404)
+})
+
+// GetValues gets the match pattern values from the http.Request context. The map is built on
+// every call, GetParams is the cheaper alternative.
 func GetValues(ctx context.Context) (map[string]string, bool) {
-	value := ctx.Value(valuesKey{})
-	if value == nil {
+	ps, ok := GetParams(ctx)
+	if !ok {
 		return nil, false
 	}
 
-	values, ok := value.(map[string]string)
-	return values, ok
+	return ps.Map(), true
+}
+
+// GetPattern gets the pattern of the matched route from the http.Request context. The pattern
+// is only available for routes registered with the Framework.
+func GetPattern(ctx context.Context) (string, bool) {
+	pattern, ok := ctx.Value(patternKey{}).(string)
+	return pattern, ok
 }
 
 func returnError(w http.ResponseWriter, msg string, code int) {
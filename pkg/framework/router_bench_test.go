@@ -0,0 +1,205 @@
+package framework_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/framework"
+)
+
+var nop = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+func benchRouter() *framework.Router {
+	rt := framework.NewRouter(nil)
+	for _, p := range []string{
+		"/",
+		"/api/v1/status",
+		"/api/v1/users",
+		"/api/v1/users/:id",
+		"/api/v1/users/:id/posts/:post",
+		"/api/v1/orgs/:org/repos/:repo/issues",
+		"/static/*",
+	} {
+		if err := rt.Handle(p, nop); err != nil {
+			panic(err)
+		}
+	}
+
+	return rt
+}
+
+func TestRouter_LookupParams_Allocs(t *testing.T) {
+	rt := benchRouter()
+
+	tests := map[string]struct {
+		path       string
+		wantParams framework.Params
+	}{
+		"should not allocate for static route": {
+			path: "/api/v1/status",
+		},
+		"should not allocate for param route": {
+			path:       "/api/v1/users/42/posts/7",
+			wantParams: framework.Params{{Key: "id", Value: "42"}, {Key: "post", Value: "7"}},
+		},
+		"should not allocate for splat route": {
+			path: "/static/css/site.css",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			ps := make(framework.Params, 0, 8)
+
+			allocs := testing.AllocsPerRun(100, func() {
+				ps = ps[:0]
+				rt.LookupParams(req, &ps)
+			})
+
+			assert.Zero(t, allocs)
+			if tt.wantParams != nil {
+				assert.Equal(t, tt.wantParams, ps)
+			} else {
+				assert.Empty(t, ps)
+			}
+		})
+	}
+}
+
+func TestGetParams(t *testing.T) {
+	rt := framework.NewRouter(nil)
+	assert.NoError(t, rt.Handle("/users/:id/posts/:post", http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			ps, ok := framework.GetParams(r.Context())
+			assert.True(t, ok)
+
+			id, _ := ps.Get("id")
+			post, _ := ps.Get("post")
+			_, _ = w.Write([]byte(id + " " + post))
+
+			values, ok := framework.GetValues(r.Context())
+			assert.True(t, ok)
+			assert.Equal(t, map[string]string{"id": "1", "post": "2"}, values)
+		})))
+
+	rec := httptest.NewRecorder()
+	rt.RouterHandler(rec, httptest.NewRequest(http.MethodGet, "/users/1/posts/2", nil))
+	assert.Equal(t, "1 2", rec.Body.String())
+
+	_, ok := framework.GetParams(httptest.NewRequest(http.MethodGet, "/", nil).Context())
+	assert.False(t, ok)
+}
+
+func benchFramework() *framework.Framework {
+	fw := framework.New()
+	fw.Get("/api/v1/status", nop)
+	fw.Get("/api/v1/orgs/:org/repos/:repo/issues", nop)
+
+	return fw
+}
+
+func TestFramework_ServeHTTP_Allocs(t *testing.T) {
+	fw := benchFramework()
+
+	tests := map[string]struct {
+		path       string
+		wantAllocs float64
+	}{
+		"should allocate the request context only for static route": {
+			path:       "/api/v1/status",
+			wantAllocs: 2,
+		},
+		"should allocate the request context only for param route": {
+			path:       "/api/v1/orgs/acme/repos/susanin/issues",
+			wantAllocs: 2,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			w := httptest.NewRecorder()
+
+			allocs := testing.AllocsPerRun(100, func() {
+				fw.ServeHTTP(w, req)
+			})
+
+			assert.Equal(t, tt.wantAllocs, allocs)
+		})
+	}
+}
+
+func benchmarkLookup(b *testing.B, path string) {
+	rt := benchRouter()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	ps := make(framework.Params, 0, 8)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		ps = ps[:0]
+		rt.LookupParams(req, &ps)
+	}
+}
+
+func BenchmarkRouter_LookupParams_Static(b *testing.B) {
+	benchmarkLookup(b, "/api/v1/status")
+}
+
+func BenchmarkRouter_LookupParams_Param(b *testing.B) {
+	benchmarkLookup(b, "/api/v1/users/42/posts/7")
+}
+
+func BenchmarkRouter_LookupParams_Splat(b *testing.B) {
+	benchmarkLookup(b, "/static/css/site.css")
+}
+
+func BenchmarkRouter_Lookup_Param(b *testing.B) {
+	rt := benchRouter()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		rt.Lookup("/api/v1/users/42/posts/7")
+	}
+}
+
+func benchmarkRouterHandler(b *testing.B, path string) {
+	rt := benchRouter()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	w := httptest.NewRecorder()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		rt.RouterHandler(w, req)
+	}
+}
+
+func BenchmarkRouter_RouterHandler_Static(b *testing.B) {
+	benchmarkRouterHandler(b, "/api/v1/status")
+}
+
+func BenchmarkRouter_RouterHandler_Param(b *testing.B) {
+	benchmarkRouterHandler(b, "/api/v1/orgs/acme/repos/susanin/issues")
+}
+
+func BenchmarkFramework_ServeHTTP_Param(b *testing.B) {
+	fw := benchFramework()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/orgs/acme/repos/susanin/issues", nil)
+	w := httptest.NewRecorder()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		fw.ServeHTTP(w, req)
+	}
+}
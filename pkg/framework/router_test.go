@@ -43,38 +43,57 @@ func TestRouter_NewRouter(t *testing.T) {
 }
 
 func TestRouter_Handle(t *testing.T) {
-	tests := map[string]struct {
-		path    string
-		handler http.HandlerFunc
-		wantErr bool
+	// the cases depend on the previously added routes, so they must run in order
+	tests := []struct {
+		name     string
+		path     string
+		handler  http.HandlerFunc
+		matchers []framework.Matcher
+		wantErr  bool
 	}{
-		"should return an error if splat is in the middle of the path": {
+		{
+			name:    "should return an error if splat is in the middle of the path",
 			path:    "/test/*/hello",
 			handler: dummy,
 			wantErr: true,
 		},
-		"should return an error if splat is there is more than one splats": {
+		{
+			name:    "should return an error if splat is there is more than one splats",
 			path:    "/test/hello/*/*",
 			handler: dummy,
 			wantErr: true,
 		},
-		"should successfully add a correct pass handler with a variable": {
+		{
+			name:    "should successfully add a correct pass handler with a variable",
 			path:    "/test/:param1/hello",
 			handler: dummy,
 		},
-		"should return an error path with different variable already exists": {
+		{
+			name:    "should return an error path with different variable already exists",
 			path:    "/test/:param2/hello",
 			handler: dummy,
 			wantErr: true,
 		},
+		{
+			name:    "should return an error if the handler already exists",
+			path:    "/test/:param1/hello",
+			handler: dummy,
+			wantErr: true,
+		},
+		{
+			name:     "should add a handler with matchers for the existing path",
+			path:     "/test/:param1/hello",
+			handler:  dummy,
+			matchers: []framework.Matcher{framework.Header("X-Version", "2")},
+		},
 	}
 
 	r := framework.NewRouter(nil)
 	assert.NotNil(t, r)
 
-	for name, tt := range tests {
-		t.Run(name, func(t *testing.T) {
-			err := r.Handle(tt.path, tt.handler)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := r.Handle(tt.path, tt.handler, tt.matchers...)
 			if tt.wantErr {
 				assert.Error(t, err)
 			} else {
@@ -181,3 +200,72 @@ func TestRouter_Lookup(t *testing.T) {
 		})
 	}
 }
+
+func TestRouter_EscapedPath(t *testing.T) {
+	tests := map[string]struct {
+		path       string
+		wantBody   string
+		wantValues map[string]string
+	}{
+		"should keep escaped slash in variable": {
+			path:       "/files/a%2Fb/info",
+			wantBody:   "dynamic",
+			wantValues: map[string]string{"name": "a/b"},
+		},
+		"should decode space in variable": {
+			path:       "/files/my%20file.txt/info",
+			wantBody:   "dynamic",
+			wantValues: map[string]string{"name": "my file.txt"},
+		},
+		"should decode unicode in variable": {
+			path:       "/files/%E6%97%A5%E6%9C%AC/info",
+			wantBody:   "dynamic",
+			wantValues: map[string]string{"name": "日本"},
+		},
+		"should decode several variables": {
+			path:       "/users/J%C3%BCrgen/M%C3%BCller%2Fjr",
+			wantBody:   "dynamic1",
+			wantValues: map[string]string{"first": "Jürgen", "last": "Müller/jr"},
+		},
+		"should match unicode constant": {
+			path:     "/caf%C3%A9",
+			wantBody: "static",
+		},
+		"should match escaped constant": {
+			path:     "/%63af%C3%A9",
+			wantBody: "static",
+		},
+		"should not split splat on escaped slash": {
+			path:     "/files/a%2Fb",
+			wantBody: "splat",
+			// the values are still filled during the longest match search
+			wantValues: map[string]string{"name": "a/b"},
+		},
+	}
+
+	r := framework.NewRouter(nil)
+	assert.NoError(t, r.Handle("/files/:name/info", dynamic))
+	assert.NoError(t, r.Handle("/files/*", splat))
+	assert.NoError(t, r.Handle("/users/:first/:last", dynamic1))
+	assert.NoError(t, r.Handle("/café", static))
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+
+			rec := httptest.NewRecorder()
+			r.RouterHandler(rec, req)
+
+			assert.Equal(t, 200, rec.Code)
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+
+			_, values := r.LookupRequest(req)
+			assert.Equal(t, tt.wantValues, values)
+		})
+	}
+
+	handler, values := r.Lookup("/files/100%/info")
+	assert.NotNil(t, handler)
+	assert.Equal(t, map[string]string{"name": "100%"}, values,
+		"invalid escapes are kept as is")
+}
@@ -0,0 +1,287 @@
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// segmentPart is either a literal or a variable part of a mixed path element.
+type segmentPart struct {
+	literal string
+	name    string // variable name, empty for the literals
+}
+
+// isVarName reports if the whole string is a variable name.
+func isVarName(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool { return !isNameRune(r) }) == -1
+}
+
+func isNameRune(r rune) bool {
+	return r == '_' || r == '-' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// isVarStart reports if a variable starts at the offset, i.e. there is a colon followed by a
+// letter or an underscore. The other colons are literal, e.g. in "12:30", and "::" is an
+// escaped colon, e.g. "urn::isbn" matches "urn:isbn".
+func isVarStart(s string, i int) bool {
+	if s[i] != ':' || i+1 == len(s) {
+		return false
+	}
+
+	r, _ := utf8.DecodeRuneInString(s[i+1:])
+	return r == '_' || unicode.IsLetter(r)
+}
+
+// isVar reports if the whole path element is a variable, e.g. ":id".
+func isVar(token string) bool {
+	return len(token) > 1 && token[0] == ':' && token[1] != ':' && isVarName(token[1:])
+}
+
+// isMixed reports if the path element combines literals and variables, e.g. ":name.:ext".
+func isMixed(token string) bool {
+	if isVar(token) {
+		return false
+	}
+
+	for i := 0; i < len(token); i++ {
+		if strings.HasPrefix(token[i:], "::") {
+			i++
+			continue
+		}
+
+		if isVarStart(token, i) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// literal returns the constant path element with the escaped colons unescaped.
+func literal(token string) string {
+	return strings.ReplaceAll(token, "::", ":")
+}
+
+// isMulti reports if the path element is a variable matching one or more elements, e.g. ":ref+".
+func isMulti(token string) bool {
+	return len(token) > 2 && token[0] == ':' && token[len(token)-1] == '+' &&
+		isVarName(token[1:len(token)-1])
+}
+
+// isOptional reports if the path element is an optional variable, e.g. ":month?".
+func isOptional(token string) bool {
+	return len(token) > 2 && token[0] == ':' && token[len(token)-1] == '?' &&
+		isVarName(token[1:len(token)-1])
+}
+
+// expandOptional returns the paths matched by the path with optional variables, e.g.
+// "/reports/:year/:month?" expands to "/reports/:year/:month" and "/reports/:year". The optional
+// variables must be at the end of the path.
+func expandOptional(path string) ([]string, error) {
+	tokens := strings.Split(path, "/")
+
+	first := len(tokens)
+	for i := len(tokens) - 1; i >= 0 && isOptional(tokens[i]); i-- {
+		tokens[i] = tokens[i][:len(tokens[i])-1]
+		first = i
+	}
+
+	for _, token := range tokens[:first] {
+		if isOptional(token) {
+			return nil, errors.New("invalid path: optional variables must be at the end of the path")
+		}
+	}
+
+	paths := make([]string, 0, len(tokens)-first+1)
+	for n := len(tokens); n >= first; n-- {
+		p := strings.Join(tokens[:n], "/")
+		if p == "" {
+			p = "/"
+		}
+
+		paths = append(paths, p)
+	}
+
+	return paths, nil
+}
+
+// parseSegment splits the mixed path element into the literal and variable parts. The variable
+// names start with a letter or an underscore and consist of letters, digits, underscores and
+// dashes.
+func parseSegment(token string) ([]segmentPart, error) {
+	var (
+		parts []segmentPart
+		lit   strings.Builder
+	)
+
+	for i := 0; i < len(token); {
+		switch {
+		case strings.HasPrefix(token[i:], "::"):
+			lit.WriteByte(':')
+			i += 2
+
+		case isVarStart(token, i):
+			if lit.Len() > 0 {
+				parts = append(parts, segmentPart{literal: lit.String()})
+				lit.Reset()
+			} else if n := len(parts); n > 0 && parts[n-1].name != "" {
+				return nil, fmt.Errorf("invalid path element %q: adjacent variables", token)
+			}
+
+			end := strings.IndexFunc(token[i+1:], func(r rune) bool { return !isNameRune(r) })
+			if end == -1 {
+				end = len(token)
+			} else {
+				end += i + 1
+			}
+
+			parts = append(parts, segmentPart{name: token[i+1 : end]})
+			i = end
+
+		default:
+			lit.WriteByte(token[i])
+			i++
+		}
+	}
+
+	if lit.Len() > 0 {
+		parts = append(parts, segmentPart{literal: lit.String()})
+	}
+
+	return parts, nil
+}
+
+// literalLen returns the total length of the literal parts.
+func literalLen(parts []segmentPart) int {
+	n := 0
+	for _, p := range parts {
+		n += len(p.literal)
+	}
+
+	return n
+}
+
+// addMixed returns the link of the mixed path element creating it if needed. The links are
+// ordered by the length of their literals, so the more specific ones are tried first.
+func (cl *chainLink) addMixed(token string) (*chainLink, error) {
+	parts, err := parseSegment(token)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, link := range cl.nextMixed {
+		if link.name == token {
+			return link, nil
+		}
+
+		if sameLiterals(link.parts, parts) {
+			return nil, errors.New("conflict: duplicate pattern at the same level")
+		}
+	}
+
+	link := &chainLink{name: token, parts: parts}
+
+	n := literalLen(parts)
+	idx := sort.Search(len(cl.nextMixed), func(i int) bool {
+		return literalLen(cl.nextMixed[i].parts) < n
+	})
+
+	cl.nextMixed = append(cl.nextMixed, nil)
+	copy(cl.nextMixed[idx+1:], cl.nextMixed[idx:])
+	cl.nextMixed[idx] = link
+
+	return link, nil
+}
+
+// sameLiterals reports if the parts differ in the variable names only.
+func sameLiterals(a, b []segmentPart) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i].literal != b[i].literal || (a[i].name == "") != (b[i].name == "") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchMixed returns the first mixed link matching the path element along with the values of
+// its variables in the order of the parts.
+func (cl *chainLink) matchMixed(value string, fold bool) (*chainLink, []string) {
+	for _, link := range cl.nextMixed {
+		if captures, ok := matchParts(link.parts, value, nil, fold); ok {
+			return link, captures
+		}
+	}
+
+	return nil, nil
+}
+
+// matchParts matches the path element against the parts. The variables match one or more
+// characters and take as many as possible, e.g. ":name.:ext" matches "a.tar.gz" with name
+// "a.tar" and ext "gz".
+func matchParts(parts []segmentPart, s string, captures []string, fold bool) ([]string, bool) {
+	if len(parts) == 0 {
+		return captures, s == ""
+	}
+
+	p := parts[0]
+	if p.name == "" {
+		if len(s) < len(p.literal) || !equalLiteral(s[:len(p.literal)], p.literal, fold) {
+			return nil, false
+		}
+
+		return matchParts(parts[1:], s[len(p.literal):], captures, fold)
+	}
+
+	if len(parts) == 1 {
+		return append(captures, s), s != ""
+	}
+
+	// the variable is always followed by a literal
+	next := parts[1].literal
+	for i := len(s) - len(next); i > 0; i-- {
+		if !equalLiteral(s[i:i+len(next)], next, fold) {
+			continue
+		}
+
+		if c, ok := matchParts(parts[1:], s[i:], append(captures, s[:i]), fold); ok {
+			return c, true
+		}
+	}
+
+	return nil, false
+}
+
+func equalLiteral(s, literal string, fold bool) bool {
+	if fold {
+		return strings.EqualFold(s, literal)
+	}
+
+	return s == literal
+}
+
+// literalCase returns the path element with the literals in the case of the route.
+func literalCase(parts []segmentPart, captures []string) string {
+	var sb strings.Builder
+
+	i := 0
+	for _, p := range parts {
+		if p.name == "" {
+			sb.WriteString(p.literal)
+			continue
+		}
+
+		sb.WriteString(captures[i])
+		i++
+	}
+
+	return sb.String()
+}
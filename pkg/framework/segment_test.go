@@ -0,0 +1,389 @@
+package framework_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/framework"
+)
+
+func TestRouter_MixedSegments(t *testing.T) {
+	tests := map[string]struct {
+		path       string
+		wantBody   string
+		wantValues map[string]string
+	}{
+		"should match name and extension": {
+			path:       "/files/report.pdf",
+			wantBody:   "dynamic",
+			wantValues: map[string]string{"name": "report", "ext": "pdf"},
+		},
+		"should take the last extension": {
+			path:       "/files/archive.tar.gz",
+			wantBody:   "dynamic",
+			wantValues: map[string]string{"name": "archive.tar", "ext": "gz"},
+		},
+		"should prefer longer literals": {
+			path:       "/files/data.json",
+			wantBody:   "dynamic1",
+			wantValues: map[string]string{"name": "data"},
+		},
+		"should fall back to variable if no mixed pattern matches": {
+			path:       "/files/README",
+			wantBody:   "static",
+			wantValues: map[string]string{"id": "README"},
+		},
+		"should not match empty variable": {
+			path:       "/files/.pdf",
+			wantBody:   "static",
+			wantValues: map[string]string{"id": ".pdf"},
+		},
+		"should match variable with suffix": {
+			path:       "/avatars/bob.png",
+			wantBody:   "static1",
+			wantValues: map[string]string{"user": "bob"},
+		},
+		"should not match different suffix": {
+			path:     "/avatars/bob.jpg",
+			wantBody: "fallback",
+		},
+		"should match prefix and several variables": {
+			path:       "/v1.12/status",
+			wantBody:   "static2",
+			wantValues: map[string]string{"major": "1", "minor": "12"},
+		},
+		"should match escaped value": {
+			path:       "/files/my%20notes.txt",
+			wantBody:   "dynamic",
+			wantValues: map[string]string{"name": "my notes", "ext": "txt"},
+		},
+	}
+
+	r := framework.NewRouter(nil)
+	assert.NoError(t, r.Handle("/files/:name.:ext", dynamic))
+	assert.NoError(t, r.Handle("/files/:name.json", dynamic1))
+	assert.NoError(t, r.Handle("/files/:id", static))
+	assert.NoError(t, r.Handle("/avatars/:user.png", static1))
+	assert.NoError(t, r.Handle("/v:major.:minor/status", static2))
+	assert.NoError(t, r.Handle("/*", fallback))
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+
+			handler, values := r.LookupRequest(req)
+			assert.NotNil(t, handler)
+			assert.Equal(t, tt.wantValues, values)
+
+			rec := httptest.NewRecorder()
+			r.RouterHandler(rec, req)
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+		})
+	}
+}
+
+func TestRouter_MixedSegments_Handle(t *testing.T) {
+	// the cases depend on the previously added routes, so they must run in order
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{
+			name: "should add mixed pattern",
+			path: "/files/:name.:ext",
+		},
+		{
+			name: "should add plain variable at the same level",
+			path: "/files/:id",
+		},
+		{
+			name: "should add mixed pattern with different literals",
+			path: "/files/:name.:ext.bak",
+		},
+		{
+			name: "should add route below the existing mixed pattern",
+			path: "/files/:name.:ext/meta",
+		},
+		{
+			name:    "should return an error if the handler already exists",
+			path:    "/files/:name.:ext",
+			wantErr: true,
+		},
+		{
+			name:    "should return an error if the pattern differs in variable names only",
+			path:    "/files/:base.:type",
+			wantErr: true,
+		},
+		{
+			name:    "should return an error on adjacent variables",
+			path:    "/files/:name:ext",
+			wantErr: true,
+		},
+		{
+			name: "should add constant with trailing colon",
+			path: "/files/v:",
+		},
+		{
+			name: "should add constant with colon followed by digits",
+			path: "/files/12:30",
+		},
+	}
+
+	r := framework.NewRouter(nil)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := r.Handle(tt.path, dummy)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRouter_ColonConstants(t *testing.T) {
+	tests := map[string]struct {
+		path       string
+		wantBody   string
+		wantValues map[string]string
+	}{
+		"should match colon followed by digits literally": {
+			path:     "/alarms/12:30",
+			wantBody: "static",
+		},
+		"should match trailing colon literally": {
+			path:     "/tags/foo:",
+			wantBody: "static1",
+		},
+		"should match escaped colon literally": {
+			path:     "/books/urn:isbn",
+			wantBody: "static2",
+		},
+		"should match escaped colon followed by variable": {
+			path:       "/books/urn:isbn:0451450523",
+			wantBody:   "dynamic",
+			wantValues: map[string]string{"isbn": "0451450523"},
+		},
+		"should not match colon constant with another value": {
+			path:     "/alarms/12:31",
+			wantBody: "fallback",
+		},
+	}
+
+	r := framework.NewRouter(nil)
+	assert.NoError(t, r.Handle("/alarms/12:30", static))
+	assert.NoError(t, r.Handle("/tags/foo:", static1))
+	assert.NoError(t, r.Handle("/books/urn::isbn", static2))
+	assert.NoError(t, r.Handle("/books/urn::isbn:::isbn", dynamic))
+	assert.NoError(t, r.Handle("/*", fallback))
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+
+			_, values := r.LookupRequest(req)
+			assert.Equal(t, tt.wantValues, values)
+
+			rec := httptest.NewRecorder()
+			r.RouterHandler(rec, req)
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+		})
+	}
+}
+
+func TestRouter_MixedSegments_Options(t *testing.T) {
+	rt := framework.NewRouter(nil, framework.CaseInsensitive(), framework.RedirectCanonical(308))
+	assert.NoError(t, rt.Handle("/v:major.:minor/status", static))
+
+	tests := map[string]struct {
+		path         string
+		wantCode     int
+		wantLocation string
+	}{
+		"should serve canonical path": {
+			path:     "/v1.2/status",
+			wantCode: 200,
+		},
+		"should redirect to the case of the route literals": {
+			path:         "/V1.2/STATUS",
+			wantCode:     308,
+			wantLocation: "/v1.2/status",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			rt.RouterHandler(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, tt.wantLocation, rec.Header().Get("Location"))
+		})
+	}
+}
+
+func TestRouter_OptionalAndMultiSegments(t *testing.T) {
+	tests := map[string]struct {
+		path       string
+		wantCode   int
+		wantBody   string
+		wantValues map[string]string
+	}{
+		"should match with optional variable": {
+			path:       "/reports/2024/05",
+			wantCode:   200,
+			wantBody:   "static",
+			wantValues: map[string]string{"year": "2024", "month": "05"},
+		},
+		"should match without optional variable": {
+			path:       "/reports/2024",
+			wantCode:   200,
+			wantBody:   "static",
+			wantValues: map[string]string{"year": "2024"},
+		},
+		"should match without several optional variables": {
+			path:     "/archive",
+			wantCode: 200,
+			wantBody: "static1",
+		},
+		"should capture several elements in the middle of the path": {
+			path:     "/repos/snobb/susanin/blob/feature/x/raw",
+			wantCode: 200,
+			wantBody: "dynamic",
+			wantValues: map[string]string{
+				"owner": "snobb", "repo": "susanin", "ref": "feature/x",
+			},
+		},
+		"should capture single element": {
+			path:     "/repos/snobb/susanin/blob/main/raw",
+			wantCode: 200,
+			wantBody: "dynamic",
+			wantValues: map[string]string{
+				"owner": "snobb", "repo": "susanin", "ref": "main",
+			},
+		},
+		"should take as many elements as possible": {
+			path:     "/repos/snobb/susanin/blob/a/raw/raw",
+			wantCode: 200,
+			wantBody: "dynamic",
+			wantValues: map[string]string{
+				"owner": "snobb", "repo": "susanin", "ref": "a/raw",
+			},
+		},
+		"should prefer constant over multi element variable": {
+			path:     "/repos/snobb/susanin/blob/latest/raw",
+			wantCode: 200,
+			wantBody: "static2",
+			wantValues: map[string]string{
+				"owner": "snobb", "repo": "susanin",
+			},
+		},
+		"should unescape captured elements": {
+			path:     "/repos/snobb/susanin/blob/a%20b/c%2Fd/raw",
+			wantCode: 200,
+			wantBody: "dynamic",
+			wantValues: map[string]string{
+				"owner": "snobb", "repo": "susanin", "ref": "a b/c/d",
+			},
+		},
+		"should capture elements at the end of the path": {
+			path:       "/docs/guide/intro",
+			wantCode:   200,
+			wantBody:   "dynamic1",
+			wantValues: map[string]string{"page": "guide/intro"},
+		},
+		"should not capture empty elements": {
+			path:     "/repos/snobb/susanin/blob/raw",
+			wantCode: 404,
+		},
+		"should drop the values of the failed sibling variable": {
+			path:       "/items/foo/b",
+			wantCode:   200,
+			wantBody:   "dummy",
+			wantValues: map[string]string{"x": "foo"},
+		},
+		"should match the sibling variable": {
+			path:       "/items/foo/c",
+			wantCode:   200,
+			wantBody:   "fallback",
+			wantValues: map[string]string{"y": "foo"},
+		},
+	}
+
+	r := framework.NewRouter(nil)
+	assert.NoError(t, r.Handle("/reports/:year/:month?", static))
+	assert.NoError(t, r.Handle("/archive/:year?/:month?", static1))
+	assert.NoError(t, r.Handle("/repos/:owner/:repo/blob/:ref+/raw", dynamic))
+	assert.NoError(t, r.Handle("/repos/:owner/:repo/blob/latest/raw", static2))
+	assert.NoError(t, r.Handle("/docs/:page+", dynamic1))
+	assert.NoError(t, r.Handle("/items/:x+/b", dummy))
+	assert.NoError(t, r.Handle("/items/:y/c", fallback))
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+
+			rec := httptest.NewRecorder()
+			r.RouterHandler(rec, req)
+			assert.Equal(t, tt.wantCode, rec.Code)
+
+			if tt.wantCode == 200 {
+				assert.Equal(t, tt.wantBody, rec.Body.String())
+
+				_, values := r.LookupRequest(req)
+				assert.Equal(t, tt.wantValues, values)
+			}
+		})
+	}
+}
+
+func TestRouter_OptionalAndMultiSegments_Handle(t *testing.T) {
+	tests := map[string]struct {
+		paths   []string
+		wantErr bool
+	}{
+		"should add optional variables": {
+			paths: []string{"/a/:b?/:c?"},
+		},
+		"should return an error on optional variable in the middle": {
+			paths:   []string{"/a/:b?/c"},
+			wantErr: true,
+		},
+		"should return an error if the optional route already exists": {
+			paths:   []string{"/a/:b", "/a/:b?"},
+			wantErr: true,
+		},
+		"should return an error on different multi element variables": {
+			paths:   []string{"/a/:b+/c", "/a/:d+/e"},
+			wantErr: true,
+		},
+		"should add multi element variables with different suffixes": {
+			paths: []string{"/a/:b+/c", "/a/:b+/d"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := framework.NewRouter(nil)
+
+			var err error
+			for _, p := range tt.paths {
+				if err = r.Handle(p, dummy); err != nil {
+					break
+				}
+			}
+
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
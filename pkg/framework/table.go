@@ -0,0 +1,241 @@
+package framework
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// routeTable is a snapshot of the routes. The served table is never modified, the changes are
+// made to a copy of it, which is swapped in atomically.
+type routeTable struct {
+	methods    [mSize]*Router
+	hosts      []*hostRoutes
+	exactHosts map[string]*hostRoutes
+}
+
+var emptyTable = &routeTable{}
+
+// routeDef is a registered route. The tables are built from the definitions.
+type routeDef struct {
+	method   int
+	pattern  string
+	handler  http.Handler // wrapped with the route middlewares
+	matchers []Matcher
+	info     RouteInfo
+}
+
+// add adds the route to the table.
+func (t *routeTable) add(def *routeDef, notFoundHandler http.Handler, opts []RouterOption) error {
+	methods := &t.methods
+	if def.info.Host != "" {
+		hr, err := t.hostRoutes(def.info.Host)
+		if err != nil {
+			return err
+		}
+
+		methods = &hr.methods
+	}
+
+	if methods[def.method] == nil {
+		methods[def.method] = NewRouter(notFoundHandler, opts...)
+	}
+
+	return methods[def.method].Handle(def.pattern, def.handler, def.matchers...)
+}
+
+// hostRoutes returns the routes of the host pattern creating them if needed.
+func (t *routeTable) hostRoutes(pattern string) (*hostRoutes, error) {
+	for _, hr := range t.hosts {
+		if hr.pattern == pattern {
+			return hr, nil
+		}
+	}
+
+	hr, err := newHostRoutes(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	if hr.exact() {
+		if t.exactHosts == nil {
+			t.exactHosts = make(map[string]*hostRoutes)
+		}
+
+		t.exactHosts[strings.Join(hr.labels, ".")] = hr
+	}
+
+	t.hosts = append(t.hosts, hr)
+	return hr, nil
+}
+
+// routeSet is a set of the route definitions along with the table built from them.
+type routeSet struct {
+	defs    []routeDef
+	staging *routeTable // table being built, nil if it must be built from defs
+}
+
+// remove removes the routes of the host, the method and the pattern.
+func (s *routeSet) remove(host, method, pattern string) bool {
+	defs := without(s.defs, host, method, pattern)
+	if len(defs) == len(s.defs) {
+		return false
+	}
+
+	s.defs, s.staging = defs, nil
+	return true
+}
+
+// without returns the definitions except for the routes of the host, the method and the
+// pattern.
+func without(defs []routeDef, host, method, pattern string) []routeDef {
+	kept := defs[:0:0]
+	for _, def := range defs {
+		if def.info.Method != method || def.info.Pattern != pattern || def.info.Host != host {
+			kept = append(kept, def)
+		}
+	}
+
+	return kept
+}
+
+// swapState is the state of a Swap in progress. The routes registered with the Framework
+// during the swap are recorded, so they are kept along with the new ones.
+type swapState struct {
+	routes     routeSet
+	concurrent []routeDef
+}
+
+// build builds a new table from the route definitions. The definitions have been added to a
+// table before, so the function panics on errors.
+func (fw *Framework) build(defs []routeDef) *routeTable {
+	t := &routeTable{}
+	for i := range defs {
+		if err := t.add(&defs[i], fw.notFoundHandler, fw.routerOptions); err != nil {
+			panic(err)
+		}
+	}
+
+	return t
+}
+
+// register adds the route to the table being built. The served table is published when the
+// next request is served, so a burst of registrations builds a single copy of it.
+// The function panics if the route cannot be added. The caller must hold the lock.
+func (fw *Framework) register(set *routeSet, def routeDef) {
+	if set.staging == nil {
+		set.staging = fw.build(set.defs)
+	}
+
+	if err := set.staging.add(&def, fw.notFoundHandler, fw.routerOptions); err != nil {
+		// the table might be modified partially, so it is built again
+		set.staging = nil
+		panic(err)
+	}
+
+	set.defs = append(set.defs, def)
+}
+
+// current returns the table to serve the request with publishing the pending changes first.
+func (fw *Framework) current() *routeTable {
+	if atomic.LoadInt32(&fw.dirty) != 0 {
+		fw.mu.Lock()
+		if atomic.LoadInt32(&fw.dirty) != 0 {
+			fw.publish()
+		}
+		fw.mu.Unlock()
+	}
+
+	if t, ok := fw.table.Load().(*routeTable); ok {
+		return t
+	}
+
+	return emptyTable
+}
+
+// publish swaps the served table with the one being built. The caller must hold the lock.
+func (fw *Framework) publish() {
+	if fw.routes.staging == nil {
+		fw.routes.staging = fw.build(fw.routes.defs)
+	}
+
+	fw.table.Store(fw.routes.staging)
+	fw.routes.staging = nil
+	atomic.StoreInt32(&fw.dirty, 0)
+}
+
+// Remove removes the routes registered for the method and the pattern, e.g. "/api/items/:id",
+// as listed by Routes. Within a WithHost group the routes of the host are removed. The function
+// returns false if there are no such routes. The routes are removed from the requests served
+// after the function returns, the requests in flight are not affected.
+func (fw *Framework) Remove(method, pattern string) bool {
+	return fw.remove(nil, fw.scope().host, method, pattern)
+}
+
+// remove removes the routes of the group host.
+func (fw *Framework) remove(sw *swapState, host, method, pattern string) bool {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	if sw != nil {
+		fw.checkSwap(sw)
+		return sw.routes.remove(host, method, pattern)
+	}
+
+	if !fw.routes.remove(host, method, pattern) {
+		return false
+	}
+
+	if fw.swap != nil {
+		fw.swap.concurrent = without(fw.swap.concurrent, host, method, pattern)
+	}
+
+	atomic.StoreInt32(&fw.dirty, 1)
+	return true
+}
+
+// checkSwap panics if the swap group is used after the swap is over. The caller must hold the
+// lock.
+func (fw *Framework) checkSwap(sw *swapState) {
+	if fw.swap != sw {
+		panic("swap group used after the swap")
+	}
+}
+
+// Swap replaces all the routes with the ones registered with the group passed to the route
+// function at once, e.g. to reconfigure the plugin routes at runtime. The requests are served
+// with the previous routes until the function returns. The routes registered with the Framework
+// and the other groups during the swap, e.g. by other goroutines, are served straight away and
+// kept along with the new ones. If the route function panics or the new routes conflict with
+// the kept ones, the previous routes are kept and the panic is propagated.
+func (fw *Framework) Swap(route func(g *Group)) *Framework {
+	fw.swapMu.Lock()
+	defer fw.swapMu.Unlock()
+
+	sw := &swapState{routes: routeSet{staging: &routeTable{}}}
+	g := &Group{fw: fw, swap: sw, scope: fw.scope()}
+
+	fw.mu.Lock()
+	fw.swap = sw
+	fw.mu.Unlock()
+
+	defer func() {
+		fw.mu.Lock()
+		fw.swap = nil
+		fw.mu.Unlock()
+	}()
+
+	route(g)
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	for _, def := range sw.concurrent {
+		fw.register(&sw.routes, def)
+	}
+
+	fw.routes = sw.routes
+	fw.publish()
+
+	return fw
+}
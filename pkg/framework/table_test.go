@@ -0,0 +1,309 @@
+package framework_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/framework"
+	"github.com/snobb/susanin/test/helper"
+)
+
+func serve(fw *framework.Framework, method, host, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if host != "" {
+		req.Host = host
+	}
+
+	rec := httptest.NewRecorder()
+	fw.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestFramework_Remove(t *testing.T) {
+	tests := map[string]struct {
+		method   string
+		pattern  string
+		group    string
+		host     string
+		path     string
+		wantOK   bool
+		wantCode int
+		wantBody string
+	}{
+		"should remove the route": {
+			method:   http.MethodGet,
+			pattern:  "/api/items/:id",
+			path:     "/api/items/42",
+			wantOK:   true,
+			wantCode: 404,
+		},
+		"should keep the route of another method": {
+			method:   http.MethodPost,
+			pattern:  "/api/items/:id",
+			path:     "/api/items/42",
+			wantCode: 200,
+			wantBody: "item",
+		},
+		"should keep the route of another pattern": {
+			method:   http.MethodGet,
+			pattern:  "/api/items",
+			path:     "/api/items/42",
+			wantCode: 200,
+			wantBody: "item",
+		},
+		"should remove the route of the host": {
+			method:   http.MethodGet,
+			pattern:  "/api/items/:id",
+			group:    "api.example.com",
+			host:     "api.example.com",
+			path:     "/api/items/42",
+			wantOK:   true,
+			wantCode: 200,
+			wantBody: "item",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			fw := framework.New()
+			fw.WithPrefix("/api", func() {
+				fw.Get("/items/:id", helper.HandlerFactory(200, "item"))
+				fw.Get("/status", helper.HandlerFactory(200, "status"))
+			})
+
+			fw.WithHost("api.example.com", func() {
+				fw.Get("/api/items/:id", helper.HandlerFactory(200, "host item"))
+			})
+
+			// the routes are served before the removal
+			assert.Equal(t, 200, serve(fw, http.MethodGet, tt.host, tt.path).Code)
+
+			var ok bool
+			if tt.group != "" {
+				fw.WithHost(tt.group, func() {
+					ok = fw.Remove(tt.method, tt.pattern)
+				})
+			} else {
+				ok = fw.Remove(tt.method, tt.pattern)
+			}
+
+			assert.Equal(t, tt.wantOK, ok)
+
+			rec := serve(fw, http.MethodGet, tt.host, tt.path)
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, rec.Body.String())
+			}
+
+			assert.Equal(t, "status", serve(fw, http.MethodGet, "", "/api/status").Body.String())
+		})
+	}
+}
+
+func TestFramework_Remove_Register(t *testing.T) {
+	fw := framework.New()
+	fw.Get("/items/:id", helper.HandlerFactory(200, "v1"))
+
+	assert.True(t, fw.Remove(http.MethodGet, "/items/:id"))
+	assert.False(t, fw.Remove(http.MethodGet, "/items/:id"))
+	assert.Empty(t, fw.Routes())
+
+	// the pattern can be registered again once removed
+	assert.NotPanics(t, func() {
+		fw.Get("/items/:id", helper.HandlerFactory(200, "v2"))
+	})
+
+	assert.Equal(t, "v2", serve(fw, http.MethodGet, "", "/items/42").Body.String())
+}
+
+func TestFramework_Swap(t *testing.T) {
+	fw := framework.New()
+	fw.Get("/plugins/a", helper.HandlerFactory(200, "a"))
+	fw.Get("/plugins/b", helper.HandlerFactory(200, "b"))
+
+	var swapped *framework.Group
+	fw.Swap(func(g *framework.Group) {
+		g.Get("/plugins/b", helper.HandlerFactory(200, "new b"))
+
+		// the previous routes are served until the swap is done
+		assert.Equal(t, "a", serve(fw, http.MethodGet, "", "/plugins/a").Body.String())
+		assert.Equal(t, "b", serve(fw, http.MethodGet, "", "/plugins/b").Body.String())
+
+		g.Group("/plugins").Get("/c", helper.HandlerFactory(200, "c"))
+		swapped = g
+	})
+
+	assert.Equal(t, 404, serve(fw, http.MethodGet, "", "/plugins/a").Code)
+	assert.Equal(t, "new b", serve(fw, http.MethodGet, "", "/plugins/b").Body.String())
+	assert.Equal(t, "c", serve(fw, http.MethodGet, "", "/plugins/c").Body.String())
+	assert.Equal(t, []framework.RouteInfo{
+		{Method: http.MethodGet, Pattern: "/plugins/b"},
+		{Method: http.MethodGet, Pattern: "/plugins/c"},
+	}, fw.Routes())
+
+	assert.Panics(t, func() {
+		swapped.Get("/plugins/d", helper.HandlerFactory(200, "d"))
+	})
+}
+
+func TestFramework_Swap_Concurrent(t *testing.T) {
+	tests := map[string]struct {
+		fail      bool
+		wantCodeA int
+	}{
+		"should keep the concurrent routes along with the new ones": {
+			wantCodeA: 404,
+		},
+		"should keep the concurrent routes on failure": {
+			fail:      true,
+			wantCodeA: 200,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			fw := framework.New()
+			fw.Get("/plugins/a", helper.HandlerFactory(200, "a"))
+
+			swap := func() {
+				fw.Swap(func(g *framework.Group) {
+					g.Get("/plugins/b", helper.HandlerFactory(200, "b"))
+
+					// the routes registered with the Framework are served straight away
+					fw.Get("/static", helper.HandlerFactory(200, "static"))
+					fw.Get("/removed", helper.HandlerFactory(200, "removed"))
+					assert.Equal(t, 200, serve(fw, http.MethodGet, "", "/static").Code)
+					assert.True(t, fw.Remove(http.MethodGet, "/removed"))
+
+					if tt.fail {
+						panic("failed")
+					}
+				})
+			}
+
+			if tt.fail {
+				assert.Panics(t, swap)
+			} else {
+				assert.NotPanics(t, swap)
+			}
+
+			assert.Equal(t, tt.wantCodeA, serve(fw, http.MethodGet, "", "/plugins/a").Code)
+			assert.Equal(t, "static", serve(fw, http.MethodGet, "", "/static").Body.String())
+			assert.Equal(t, 404, serve(fw, http.MethodGet, "", "/removed").Code)
+		})
+	}
+}
+
+func TestFramework_Swap_Conflict(t *testing.T) {
+	fw := framework.New()
+	fw.Get("/plugins/a", helper.HandlerFactory(200, "a"))
+
+	assert.Panics(t, func() {
+		fw.Swap(func(g *framework.Group) {
+			g.Get("/static", helper.HandlerFactory(200, "new static"))
+			fw.Get("/static", helper.HandlerFactory(200, "static"))
+		})
+	})
+
+	// the concurrent route is kept with the previous routes
+	assert.Equal(t, "a", serve(fw, http.MethodGet, "", "/plugins/a").Body.String())
+	assert.Equal(t, "static", serve(fw, http.MethodGet, "", "/static").Body.String())
+}
+
+func TestFramework_Swap_Panic(t *testing.T) {
+	fw := framework.New()
+	fw.Get("/plugins/a", helper.HandlerFactory(200, "a"))
+
+	assert.Panics(t, func() {
+		fw.Swap(func(g *framework.Group) {
+			g.Get("/plugins/b", helper.HandlerFactory(200, "b"))
+			g.Get("/plugins/b", helper.HandlerFactory(200, "b"))
+		})
+	})
+
+	// the previous routes are kept
+	assert.Equal(t, "a", serve(fw, http.MethodGet, "", "/plugins/a").Body.String())
+	assert.Equal(t, 404, serve(fw, http.MethodGet, "", "/plugins/b").Code)
+	assert.Len(t, fw.Routes(), 1)
+}
+
+func TestFramework_Clear_Serving(t *testing.T) {
+	fw := framework.New()
+	fw.Get("/a", helper.HandlerFactory(200, "a"))
+	assert.Equal(t, 200, serve(fw, http.MethodGet, "", "/a").Code)
+
+	fw.Clear()
+	assert.Equal(t, 404, serve(fw, http.MethodGet, "", "/a").Code)
+
+	fw.Get("/a", helper.HandlerFactory(200, "a"))
+	assert.Equal(t, 200, serve(fw, http.MethodGet, "", "/a").Code)
+}
+
+// TestFramework_Concurrent registers, removes and swaps the routes while serving. It is meant
+// to be run with the race detector.
+func TestFramework_Concurrent(t *testing.T) {
+	fw := framework.New()
+	fw.Get("/static", helper.HandlerFactory(200, "static"))
+
+	var wg sync.WaitGroup
+
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				rec := serve(fw, http.MethodGet, "", "/static")
+				assert.Equal(t, 200, rec.Code)
+				serve(fw, http.MethodGet, "", "/plugins/1")
+			}
+		}()
+	}
+
+	var writers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		writers.Add(1)
+		go func(i int) {
+			defer writers.Done()
+			for j := 0; j < 50; j++ {
+				pattern := fmt.Sprintf("/plugins/%d/%d", i, j)
+				fw.Get(pattern, helper.HandlerFactory(200, pattern))
+				serve(fw, http.MethodGet, "", pattern)
+
+				// the route might have been swapped out already
+				if j%2 == 0 {
+					fw.Remove(http.MethodGet, pattern)
+				}
+
+				_ = fw.Routes()
+			}
+		}(i)
+	}
+
+	writers.Add(1)
+	go func() {
+		defer writers.Done()
+		for j := 0; j < 20; j++ {
+			fw.Swap(func(g *framework.Group) {
+				g.Get("/static", helper.HandlerFactory(200, "static"))
+				g.Get(fmt.Sprintf("/plugins/swap/%d", j), helper.HandlerFactory(200, "swap"))
+			})
+		}
+	}()
+
+	writers.Wait()
+	close(stop)
+	wg.Wait()
+}
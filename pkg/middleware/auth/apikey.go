@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+)
+
+// DefaultAPIKeyHeader is the default header carrying the API key.
+const DefaultAPIKeyHeader = "X-API-Key"
+
+// APIKeyConfig is the API key authenticator configuration.
+type APIKeyConfig struct {
+	// Header is the name of the header carrying the key. Zero value means DefaultAPIKeyHeader.
+	Header string
+
+	// Query is the name of the query parameter carrying the key. Empty value disables the
+	// query parameter as keys in URLs tend to end up in the logs.
+	Query string
+
+	// Keys maps the API keys to the identities of their owners.
+	Keys map[string]*Identity
+}
+
+// APIKey is the API key authenticator.
+type APIKey struct {
+	header string
+	query  string
+	keys   map[[sha256.Size]byte]*Identity
+}
+
+// NewAPIKey creates a new API key authenticator. The keys are looked up by their SHA-256
+// digests, so the lookup time does not depend on how much of the key matches. The function
+// panics if any of the identities is nil.
+func NewAPIKey(cfg APIKeyConfig) *APIKey {
+	a := &APIKey{
+		header: cfg.Header,
+		query:  cfg.Query,
+		keys:   make(map[[sha256.Size]byte]*Identity, len(cfg.Keys)),
+	}
+
+	if a.header == "" {
+		a.header = DefaultAPIKeyHeader
+	}
+
+	for key, id := range cfg.Keys {
+		if id == nil {
+			panic("auth: nil API key identity")
+		}
+
+		a.keys[sha256.Sum256([]byte(key))] = id.clone()
+	}
+
+	return a
+}
+
+// Authenticate implements the Authenticator interface.
+func (a *APIKey) Authenticate(r *http.Request) (*Identity, error) {
+	key := r.Header.Get(a.header)
+	if key == "" && a.query != "" {
+		key = r.URL.Query().Get(a.query)
+	}
+
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	owner, ok := a.keys[sha256.Sum256([]byte(key))]
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	// the handlers get their own copy, so they cannot modify the identity of the key
+	id := owner.clone()
+	id.Method = "apikey"
+
+	return id, nil
+}
+
+// Challenge implements the Authenticator interface.
+func (a *APIKey) Challenge() string {
+	return fmt.Sprintf("APIKey header=%q", a.header)
+}
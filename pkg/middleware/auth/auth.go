@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/snobb/susanin/pkg/framework"
+	"github.com/snobb/susanin/pkg/middleware"
+	"github.com/snobb/susanin/pkg/middleware/response"
+)
+
+var (
+	// ErrNoCredentials is returned by an Authenticator if the request has no credentials it
+	// can handle, so the next authenticator is tried.
+	ErrNoCredentials = errors.New("no credentials")
+
+	// ErrInvalidCredentials is returned by an Authenticator if the credentials are invalid.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Identity is the authenticated principal.
+type Identity struct {
+	Subject string                 // user name, key name or token subject
+	Method  string                 // authentication method, e.g. "basic", "apikey" or "jwt"
+	Roles   []string               // roles granted to the principal
+	Scopes  []string               // scopes granted to the principal
+	Claims  map[string]interface{} // extra attributes, e.g. token claims
+}
+
+// Permissions implements the framework.Principal interface.
+func (id *Identity) Permissions() []string {
+	return append(append([]string{}, id.Roles...), id.Scopes...)
+}
+
+// clone returns a copy of the identity with its own roles, scopes and claims map, so the copy
+// can be modified without affecting the identity. The claim values are shared.
+func (id *Identity) clone() *Identity {
+	c := *id
+	c.Roles = append([]string(nil), id.Roles...)
+	c.Scopes = append([]string(nil), id.Scopes...)
+
+	if id.Claims != nil {
+		c.Claims = make(map[string]interface{}, len(id.Claims))
+		for k, v := range id.Claims {
+			c.Claims[k] = v
+		}
+	}
+
+	return &c
+}
+
+// Authenticator authenticates the requests.
+type Authenticator interface {
+	// Authenticate returns the identity of the request principal. ErrNoCredentials is returned
+	// if the request does not carry the credentials of this type.
+	Authenticate(r *http.Request) (*Identity, error)
+
+	// Challenge returns the WWW-Authenticate header value or an empty string.
+	Challenge() string
+}
+
+// New creates an authentication middleware. The authenticators are tried in order and the
+// first successfully authenticated principal is stored in the request context. If none of the
+// authenticators succeeds the request is responded with HTTP 401 and the WWW-Authenticate
+// challenges of all the authenticators.
+func New(authenticators ...Authenticator) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			err := ErrNoCredentials
+
+			for _, a := range authenticators {
+				id, aerr := a.Authenticate(r)
+				if aerr == nil && id != nil {
+					next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), id)))
+					return
+				}
+
+				if !errors.Is(aerr, ErrNoCredentials) {
+					err = ErrInvalidCredentials
+				}
+			}
+
+			Unauthorized(w, r, err, authenticators...)
+		})
+	}
+}
+
+// Unauthorized responds with HTTP 401 and the WWW-Authenticate challenges of the
+// authenticators.
+func Unauthorized(w http.ResponseWriter, r *http.Request, err error,
+	authenticators ...Authenticator) {
+	for _, a := range authenticators {
+		if challenge := a.Challenge(); challenge != "" {
+			w.Header().Add("WWW-Authenticate", challenge)
+		}
+	}
+
+	_ = response.New(w).Error(r.Context(), http.StatusUnauthorized, err)
+}
+
+// WithPrincipal returns a copy of the context with the principal identity stored.
+func WithPrincipal(ctx context.Context, id *Identity) context.Context {
+	if id == nil {
+		return framework.WithPrincipal(ctx, nil)
+	}
+
+	return framework.WithPrincipal(ctx, id)
+}
+
+// Principal gets the authenticated principal identity from the context.
+func Principal(ctx context.Context) (*Identity, bool) {
+	p, _ := framework.GetPrincipal(ctx)
+	id, ok := p.(*Identity)
+	return id, ok && id != nil
+}
+
+// Subject returns the authenticated principal subject or an empty string. The function can be
+// used as a rate limiting key.
+func Subject(r *http.Request) string {
+	if id, ok := Principal(r.Context()); ok {
+		return id.Subject
+	}
+
+	return ""
+}
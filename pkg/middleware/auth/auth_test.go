@@ -0,0 +1,167 @@
+package auth_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/middleware/auth"
+)
+
+func TestAuth_New(t *testing.T) {
+	basic := auth.NewBasic("admin", auth.Credentials{"john": "secret"})
+	basic.Roles = map[string][]string{"john": {"admin"}}
+
+	apiKey := auth.NewAPIKey(auth.APIKeyConfig{
+		Query: "api_key",
+		Keys: map[string]*auth.Identity{
+			"k3y": {Subject: "billing", Scopes: []string{"invoices:read"}},
+		},
+	})
+
+	tests := map[string]struct {
+		setup         func(r *http.Request)
+		wantCode      int
+		wantPrincipal *auth.Identity
+		wantBody      string
+	}{
+		"should authenticate with basic credentials": {
+			setup:    func(r *http.Request) { r.SetBasicAuth("john", "secret") },
+			wantCode: 200,
+			wantPrincipal: &auth.Identity{
+				Subject: "john",
+				Method:  "basic",
+				Roles:   []string{"admin"},
+			},
+		},
+		"should authenticate with API key header": {
+			setup:    func(r *http.Request) { r.Header.Set("X-API-Key", "k3y") },
+			wantCode: 200,
+			wantPrincipal: &auth.Identity{
+				Subject: "billing",
+				Method:  "apikey",
+				Scopes:  []string{"invoices:read"},
+			},
+		},
+		"should authenticate with API key query parameter": {
+			setup:    func(r *http.Request) { r.URL.RawQuery = "api_key=k3y" },
+			wantCode: 200,
+			wantPrincipal: &auth.Identity{
+				Subject: "billing",
+				Method:  "apikey",
+				Scopes:  []string{"invoices:read"},
+			},
+		},
+		"should reject the request without credentials": {
+			setup:    func(r *http.Request) {},
+			wantCode: 401,
+			wantBody: `{"code":401,"error":"no credentials","message":"Unauthorized"}`,
+		},
+		"should reject invalid password": {
+			setup:    func(r *http.Request) { r.SetBasicAuth("john", "guess") },
+			wantCode: 401,
+			wantBody: `{"code":401,"error":"invalid credentials","message":"Unauthorized"}`,
+		},
+		"should reject unknown user": {
+			setup:    func(r *http.Request) { r.SetBasicAuth("jane", "secret") },
+			wantCode: 401,
+			wantBody: `{"code":401,"error":"invalid credentials","message":"Unauthorized"}`,
+		},
+		"should reject invalid API key": {
+			setup:    func(r *http.Request) { r.Header.Set("X-API-Key", "key") },
+			wantCode: 401,
+			wantBody: `{"code":401,"error":"invalid credentials","message":"Unauthorized"}`,
+		},
+	}
+
+	h := auth.New(basic, apiKey)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := auth.Principal(r.Context())
+		assert.True(t, ok)
+		assert.Equal(t, id.Subject, auth.Subject(r))
+		_ = json.NewEncoder(w).Encode(id)
+	}))
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			tt.setup(req)
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+
+			if tt.wantPrincipal == nil {
+				assert.Equal(t, tt.wantBody, strings.TrimSpace(rec.Body.String()))
+				assert.Equal(t, []string{
+					`Basic realm="admin", charset="UTF-8"`,
+					`APIKey header="X-API-Key"`,
+				}, rec.Header()["Www-Authenticate"])
+				return
+			}
+
+			var got auth.Identity
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+			assert.Equal(t, tt.wantPrincipal, &got)
+		})
+	}
+}
+
+func TestAuth_Principal(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	_, ok := auth.Principal(req.Context())
+	assert.False(t, ok)
+	assert.Equal(t, "", auth.Subject(req))
+
+	ctx := auth.WithPrincipal(req.Context(), &auth.Identity{Subject: "john"})
+	id, ok := auth.Principal(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "john", id.Subject)
+}
+
+func TestAuth_APIKey_Copy(t *testing.T) {
+	apiKey := auth.NewAPIKey(auth.APIKeyConfig{
+		Keys: map[string]*auth.Identity{
+			"k3y": {
+				Subject: "billing",
+				Roles:   []string{"service"},
+				Scopes:  []string{"invoices:read"},
+				Claims:  map[string]interface{}{"tenant": "acme"},
+			},
+		},
+	})
+
+	authenticate := func() *auth.Identity {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(auth.DefaultAPIKeyHeader, "k3y")
+
+		id, err := apiKey.Authenticate(req)
+		assert.NoError(t, err)
+		return id
+	}
+
+	// the handlers cannot modify the identity of the key
+	id := authenticate()
+	id.Roles[0] = "admin"
+	id.Scopes[0] = "invoices:write"
+	id.Claims["tenant"] = "evil"
+
+	assert.Equal(t, &auth.Identity{
+		Subject: "billing",
+		Method:  "apikey",
+		Roles:   []string{"service"},
+		Scopes:  []string{"invoices:read"},
+		Claims:  map[string]interface{}{"tenant": "acme"},
+	}, authenticate())
+}
+
+func TestAuth_APIKey_Invalid(t *testing.T) {
+	assert.Panics(t, func() {
+		auth.NewAPIKey(auth.APIKeyConfig{Keys: map[string]*auth.Identity{"k3y": nil}})
+	})
+}
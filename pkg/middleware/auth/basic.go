@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+)
+
+// CredentialStore verifies the user credentials.
+type CredentialStore interface {
+	// Verify checks the password of the user. The implementations should not leak whether
+	// the user exists through the timing.
+	Verify(user, password string) bool
+}
+
+// Credentials is a CredentialStore with plain text passwords, e.g. loaded from the
+// configuration. The passwords are compared in constant time.
+type Credentials map[string]string
+
+// Verify implements the CredentialStore interface.
+func (c Credentials) Verify(user, password string) bool {
+	want, ok := c[user]
+
+	// compare the digests so that the comparison does not depend on the password length.
+	wantSum := sha256.Sum256([]byte(want))
+	gotSum := sha256.Sum256([]byte(password))
+
+	return subtle.ConstantTimeCompare(wantSum[:], gotSum[:]) == 1 && ok
+}
+
+// Basic is the HTTP Basic authenticator.
+type Basic struct {
+	Realm string
+	Store CredentialStore
+
+	// Roles maps the users to their roles.
+	Roles map[string][]string
+}
+
+// NewBasic creates a new HTTP Basic authenticator.
+func NewBasic(realm string, store CredentialStore) *Basic {
+	return &Basic{Realm: realm, Store: store}
+}
+
+// Authenticate implements the Authenticator interface.
+func (b *Basic) Authenticate(r *http.Request) (*Identity, error) {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	if !b.Store.Verify(user, password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &Identity{
+		Subject: user,
+		Method:  "basic",
+		Roles:   b.Roles[user],
+	}, nil
+}
+
+// Challenge implements the Authenticator interface.
+func (b *Basic) Challenge() string {
+	return fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", b.Realm)
+}
@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1" // #nosec G505 - {SHA} and {SSHA} are required for htpasswd compatibility
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"os"
+	"strings"
+	"sync"
+)
+
+type hashScheme struct {
+	newHash func() hash.Hash
+	salted  bool
+}
+
+// supported password hash schemes. bcrypt and apr1 are not available in the standard library.
+var hashSchemes = map[string]hashScheme{
+	"SHA":     {sha1.New, false},
+	"SSHA":    {sha1.New, true},
+	"SHA256":  {sha256.New, false},
+	"SSHA256": {sha256.New, true},
+	"SHA512":  {sha512.New, false},
+	"SSHA512": {sha512.New, true},
+}
+
+// dummyHash is verified for unknown users to keep the timing the same.
+var dummyHash = "{SSHA512}" + base64.StdEncoding.EncodeToString(make([]byte, sha512.Size+16))
+
+// Htpasswd is a CredentialStore backed by an htpasswd style file with "user:hash" lines.
+// The supported hashes are {SHA} (as generated by htpasswd -s), {SSHA} and their SHA-256
+// and SHA-512 variants {SHA256}, {SSHA256}, {SHA512} and {SSHA512} (the digest followed by
+// the salt, base64 encoded). bcrypt and MD5 (apr1) hashes are rejected as they are not
+// supported by the standard library.
+type Htpasswd struct {
+	path  string
+	mu    sync.RWMutex
+	users map[string]string
+}
+
+// LoadHtpasswd loads the htpasswd file.
+func LoadHtpasswd(path string) (*Htpasswd, error) {
+	h := &Htpasswd{path: path}
+	if err := h.Reload(); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// Reload reloads the file. The current users are kept if the file is invalid.
+func (h *Htpasswd) Reload() error {
+	f, err := os.Open(h.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		idx := strings.IndexByte(line, ':')
+		if idx <= 0 {
+			return fmt.Errorf("htpasswd: %s:%d: invalid line", h.path, n)
+		}
+
+		user, pwhash := line[:idx], line[idx+1:]
+		if err := checkHash(pwhash); err != nil {
+			return fmt.Errorf("htpasswd: %s:%d: %w", h.path, n, err)
+		}
+
+		users[user] = pwhash
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	h.users = users
+	h.mu.Unlock()
+
+	return nil
+}
+
+// Verify implements the CredentialStore interface.
+func (h *Htpasswd) Verify(user, password string) bool {
+	h.mu.RLock()
+	pwhash, ok := h.users[user]
+	h.mu.RUnlock()
+
+	if !ok {
+		verifyHash(dummyHash, password)
+		return false
+	}
+
+	return verifyHash(pwhash, password)
+}
+
+// HashPassword hashes the password with a random salt in the {SSHA512} format.
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	h := sha512.New()
+	h.Write([]byte(password))
+	h.Write(salt)
+
+	return "{SSHA512}" + base64.StdEncoding.EncodeToString(append(h.Sum(nil), salt...)), nil
+}
+
+func splitHash(pwhash string) (string, string) {
+	if !strings.HasPrefix(pwhash, "{") {
+		return "", pwhash
+	}
+
+	idx := strings.IndexByte(pwhash, '}')
+	if idx == -1 {
+		return "", pwhash
+	}
+
+	return strings.ToUpper(pwhash[1:idx]), pwhash[idx+1:]
+}
+
+func checkHash(pwhash string) error {
+	switch {
+	case strings.HasPrefix(pwhash, "$2a$"), strings.HasPrefix(pwhash, "$2b$"),
+		strings.HasPrefix(pwhash, "$2y$"):
+		return fmt.Errorf("bcrypt hashes are not supported, use {SSHA512} instead")
+
+	case strings.HasPrefix(pwhash, "$apr1$"):
+		return fmt.Errorf("apr1 hashes are not supported, use {SSHA512} instead")
+	}
+
+	name, digest := splitHash(pwhash)
+
+	scheme, ok := hashSchemes[name]
+	if !ok {
+		return fmt.Errorf("unsupported password hash")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(digest)
+	if err != nil {
+		return fmt.Errorf("invalid password hash: %w", err)
+	}
+
+	size := scheme.newHash().Size()
+	if len(raw) < size || (!scheme.salted && len(raw) != size) {
+		return fmt.Errorf("invalid password hash length")
+	}
+
+	return nil
+}
+
+func verifyHash(pwhash, password string) bool {
+	name, digest := splitHash(pwhash)
+
+	scheme, ok := hashSchemes[name]
+	if !ok {
+		return false
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(digest)
+	if err != nil {
+		return false
+	}
+
+	h := scheme.newHash()
+	size := h.Size()
+
+	if len(raw) < size {
+		return false
+	}
+
+	h.Write([]byte(password))
+	if scheme.salted {
+		h.Write(raw[size:])
+	}
+
+	return subtle.ConstantTimeCompare(h.Sum(nil), raw[:size]) == 1
+}
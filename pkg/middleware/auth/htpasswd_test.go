@@ -0,0 +1,123 @@
+package auth_test
+
+import (
+	"crypto/sha1" // #nosec G505
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/middleware/auth"
+)
+
+func TestHtpasswd_Verify(t *testing.T) {
+	sum := sha1.Sum([]byte("secret")) // #nosec G401
+	salted, err := auth.HashPassword("s3cr3t")
+	assert.NoError(t, err)
+
+	path := writeFile(t, strings.Join([]string{
+		"# users",
+		"",
+		"john:{SHA}" + base64.StdEncoding.EncodeToString(sum[:]),
+		"jane:" + salted,
+	}, "\n"))
+
+	store, err := auth.LoadHtpasswd(path)
+	assert.NoError(t, err)
+
+	tests := map[string]struct {
+		user     string
+		password string
+		want     bool
+	}{
+		"should verify {SHA} password": {
+			user:     "john",
+			password: "secret",
+			want:     true,
+		},
+		"should verify {SSHA512} password": {
+			user:     "jane",
+			password: "s3cr3t",
+			want:     true,
+		},
+		"should reject invalid password": {
+			user:     "jane",
+			password: "secret",
+		},
+		"should reject unknown user": {
+			user:     "joe",
+			password: "secret",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, store.Verify(tt.user, tt.password))
+		})
+	}
+}
+
+func TestHtpasswd_Load(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		wantErr string
+	}{
+		"should reject bcrypt hashes": {
+			content: "john:$2y$05$c4WoMPo3SXsafkva.HHa6uXQZWr7oboPiC2bT/r7q1BB8I2s0BRqC",
+			wantErr: "bcrypt hashes are not supported",
+		},
+		"should reject apr1 hashes": {
+			content: "john:$apr1$salt$hash",
+			wantErr: "apr1 hashes are not supported",
+		},
+		"should reject plain text passwords": {
+			content: "john:secret",
+			wantErr: "unsupported password hash",
+		},
+		"should reject invalid lines": {
+			content: "john",
+			wantErr: "invalid line",
+		},
+		"should reject invalid hash length": {
+			content: "john:{SHA256}" + base64.StdEncoding.EncodeToString([]byte("short")),
+			wantErr: "invalid password hash length",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := auth.LoadHtpasswd(writeFile(t, tt.content))
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestHtpasswd_Reload(t *testing.T) {
+	hash, err := auth.HashPassword("secret")
+	assert.NoError(t, err)
+
+	path := writeFile(t, "john:"+hash)
+	store, err := auth.LoadHtpasswd(path)
+	assert.NoError(t, err)
+	assert.True(t, store.Verify("john", "secret"))
+
+	assert.NoError(t, os.WriteFile(path, []byte("jane:"+hash), 0o600))
+	assert.NoError(t, store.Reload())
+	assert.False(t, store.Verify("john", "secret"))
+	assert.True(t, store.Verify("jane", "secret"))
+
+	// invalid file keeps the current users
+	assert.NoError(t, os.WriteFile(path, []byte("joe:secret"), 0o600))
+	assert.Error(t, store.Reload())
+	assert.True(t, store.Verify("jane", "secret"))
+}
+
+func writeFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
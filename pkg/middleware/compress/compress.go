@@ -0,0 +1,377 @@
+package compress
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/snobb/susanin/pkg/middleware"
+)
+
+const (
+	encGzip    = "gzip"
+	encDeflate = "deflate"
+
+	// DefaultMinSize is the minimal body size in bytes that is worth compressing.
+	DefaultMinSize = 1024
+)
+
+// DefaultSkipTypes is a list of content type prefixes that are already compressed and are
+// passed through as is.
+var DefaultSkipTypes = []string{
+	"image/",
+	"audio/",
+	"video/",
+	"font/woff",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/x-bzip2",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/zstd",
+	"application/octet-stream",
+}
+
+// Config is the compression middleware configuration.
+type Config struct {
+	// Level is the compression level as defined in compress/flate. Zero value means
+	// flate.DefaultCompression.
+	Level int
+
+	// MinSize is the minimal size of the response body to be compressed. Smaller bodies are
+	// written as is. Zero value means DefaultMinSize.
+	MinSize int
+
+	// SkipTypes is a list of content type prefixes that are never compressed. If nil,
+	// DefaultSkipTypes is used.
+	SkipTypes []string
+}
+
+// encoder is a common interface of the gzip and zlib writers.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+type compressor struct {
+	minSize   int
+	skipTypes []string
+	pools     map[string]*sync.Pool
+}
+
+// New creates a new compression middleware. The middleware negotiates the encoding using the
+// Accept-Encoding request header and streams the compressed body to the client.
+// The function panics if the configured compression level is invalid.
+func New(cfg Config) middleware.Middleware {
+	level := cfg.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		panic(fmt.Sprintf("compress: invalid compression level: %d", level))
+	}
+
+	c := &compressor{
+		minSize:   cfg.MinSize,
+		skipTypes: cfg.SkipTypes,
+		pools: map[string]*sync.Pool{
+			encGzip: {New: func() interface{} {
+				w, _ := gzip.NewWriterLevel(io.Discard, level)
+				return w
+			}},
+			encDeflate: {New: func() interface{} {
+				w, _ := zlib.NewWriterLevel(io.Discard, level)
+				return w
+			}},
+		},
+	}
+
+	if c.minSize == 0 {
+		c.minSize = DefaultMinSize
+	}
+
+	if c.skipTypes == nil {
+		c.skipTypes = DefaultSkipTypes
+	}
+
+	return c.middleware
+}
+
+func (c *compressor) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addVary(w.Header(), "Accept-Encoding")
+
+		encoding := negotiate(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{
+			ResponseWriter: w,
+			c:              c,
+			encoding:       encoding,
+			status:         http.StatusOK,
+		}
+		defer cw.close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter buffers the beginning of the response until it is large enough to decide
+// whether to compress it. After that all the writes are streamed.
+type compressWriter struct {
+	http.ResponseWriter
+	c        *compressor
+	encoding string
+	status   int
+	buf      []byte
+	enc      encoder
+	decided  bool
+}
+
+// WriteHeader stores the status code until the encoding decision is made.
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.decided {
+		return
+	}
+
+	cw.status = status
+}
+
+// Write buffers the data until MinSize is reached and then streams the compressed data.
+func (cw *compressWriter) Write(data []byte) (int, error) {
+	if !cw.decided {
+		if !cw.eligible() {
+			if err := cw.passthrough(); err != nil {
+				return 0, err
+			}
+		} else {
+			cw.buf = append(cw.buf, data...)
+			if len(cw.buf) < cw.c.minSize {
+				return len(data), nil
+			}
+
+			if err := cw.start(); err != nil {
+				return 0, err
+			}
+
+			return len(data), nil
+		}
+	}
+
+	if cw.enc != nil {
+		return cw.enc.Write(data)
+	}
+
+	return cw.ResponseWriter.Write(data)
+}
+
+// Flush implements the http.Flusher interface. Flushing before MinSize is reached forces
+// compression of eligible responses so that streaming endpoints keep working.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		var err error
+		if cw.eligible() {
+			err = cw.start()
+		} else {
+			err = cw.passthrough()
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	if cw.enc != nil {
+		_ = cw.enc.Flush()
+	}
+
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// eligible checks the response status and headers to see if the response can be compressed.
+func (cw *compressWriter) eligible() bool {
+	switch {
+	case cw.status < http.StatusOK,
+		cw.status == http.StatusNoContent,
+		cw.status == http.StatusNotModified,
+		cw.status == http.StatusPartialContent:
+		return false
+	}
+
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+
+	if cl := h.Get("Content-Length"); cl != "" {
+		if n, err := strconv.Atoi(cl); err == nil && n < cw.c.minSize {
+			return false
+		}
+	}
+
+	ct := h.Get("Content-Type")
+	for _, prefix := range cw.c.skipTypes {
+		if strings.HasPrefix(ct, prefix) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// start writes the headers and begins streaming the compressed body.
+func (cw *compressWriter) start() error {
+	cw.decided = true
+
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		// the server sniffs the content type from the first write, which is going to be
+		// compressed, so it has to be done here.
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", cw.encoding)
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	cw.enc = cw.c.pools[cw.encoding].Get().(encoder)
+	cw.enc.Reset(cw.ResponseWriter)
+
+	buf := cw.buf
+	cw.buf = nil
+
+	if len(buf) == 0 {
+		return nil
+	}
+
+	_, err := cw.enc.Write(buf)
+	return err
+}
+
+// passthrough writes the headers and the buffered data as is.
+func (cw *compressWriter) passthrough() error {
+	cw.decided = true
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+
+	if len(buf) == 0 {
+		return nil
+	}
+
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
+
+// close finalises the response and returns the encoder to the pool.
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		if cw.buf == nil && cw.status == http.StatusOK {
+			// nothing was written - let the server write the default response.
+			return
+		}
+
+		_ = cw.passthrough()
+		return
+	}
+
+	if cw.enc != nil {
+		_ = cw.enc.Close()
+		cw.enc.Reset(io.Discard)
+		cw.c.pools[cw.encoding].Put(cw.enc)
+		cw.enc = nil
+	}
+}
+
+// negotiate picks the best supported encoding from the Accept-Encoding header value.
+// Gzip is preferred over deflate if the weights are equal.
+func negotiate(accept string) string {
+	if accept == "" {
+		return ""
+	}
+
+	weights := map[string]float64{}
+	wildcard := -1.0
+
+	for _, part := range strings.Split(accept, ",") {
+		coding, q := parseCoding(part)
+		if coding == "" {
+			continue
+		}
+
+		if coding == "*" {
+			wildcard = q
+			continue
+		}
+
+		weights[coding] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, enc := range []string{encGzip, encDeflate} {
+		q, ok := weights[enc]
+		if !ok {
+			q = wildcard
+		}
+
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+
+	return best
+}
+
+// parseCoding parses a single "coding;q=value" element of the Accept-Encoding header.
+func parseCoding(part string) (string, float64) {
+	params := strings.Split(part, ";")
+	coding := strings.ToLower(strings.TrimSpace(params[0]))
+	if coding == "x-gzip" {
+		coding = encGzip
+	}
+
+	q := 1.0
+	for _, param := range params[1:] {
+		param = strings.TrimSpace(param)
+		if len(param) < 2 || (param[0] != 'q' && param[0] != 'Q') || param[1] != '=' {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(param[2:], 64)
+		if err != nil {
+			return "", 0
+		}
+
+		q = v
+	}
+
+	return coding, q
+}
+
+// addVary adds the value to the Vary header unless it is already there.
+func addVary(h http.Header, value string) {
+	for _, v := range h["Vary"] {
+		for _, field := range strings.Split(v, ",") {
+			field = strings.TrimSpace(field)
+			if field == "*" || strings.EqualFold(field, value) {
+				return
+			}
+		}
+	}
+
+	h.Add("Vary", value)
+}
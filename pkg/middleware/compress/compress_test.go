@@ -0,0 +1,176 @@
+package compress_test
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/middleware/compress"
+)
+
+func TestCompress_New(t *testing.T) {
+	large := strings.Repeat("susanin ", 512)
+
+	tests := map[string]struct {
+		accept       string
+		contentType  string
+		body         string
+		wantEncoding string
+	}{
+		"should not compress if the client does not accept any encoding": {
+			body: large,
+		},
+		"should compress large body with gzip": {
+			accept:       "gzip, deflate",
+			body:         large,
+			wantEncoding: "gzip",
+		},
+		"should compress large body with deflate if preferred": {
+			accept:       "gzip;q=0.5, deflate",
+			body:         large,
+			wantEncoding: "deflate",
+		},
+		"should compress with gzip if accepted via wildcard": {
+			accept:       "br, *",
+			body:         large,
+			wantEncoding: "gzip",
+		},
+		"should not compress if all the encodings are rejected": {
+			accept: "gzip;q=0, deflate;q=0",
+			body:   large,
+		},
+		"should not compress small body": {
+			accept: "gzip",
+			body:   "small",
+		},
+		"should not compress already compressed content types": {
+			accept:      "gzip",
+			contentType: "image/png",
+			body:        large,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := compress.New(compress.Config{})(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					if tt.contentType != "" {
+						w.Header().Set("Content-Type", tt.contentType)
+					}
+					w.WriteHeader(201)
+					_, _ = io.WriteString(w, tt.body[:len(tt.body)/2])
+					_, _ = io.WriteString(w, tt.body[len(tt.body)/2:])
+				}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept-Encoding", tt.accept)
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, 201, rec.Code)
+			assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+			assert.Equal(t, tt.wantEncoding, rec.Header().Get("Content-Encoding"))
+			assert.Equal(t, tt.body, decode(t, tt.wantEncoding, rec.Body))
+
+			if tt.wantEncoding != "" {
+				assert.Empty(t, rec.Header().Get("Content-Length"))
+				assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestCompress_ContentLength(t *testing.T) {
+	h := compress.New(compress.Config{MinSize: 4})(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", "11")
+			_, _ = io.WriteString(w, "hello world")
+		}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Empty(t, rec.Header().Get("Content-Length"))
+	assert.Equal(t, "hello world", decode(t, "gzip", rec.Body))
+}
+
+func TestCompress_Flush(t *testing.T) {
+	h := compress.New(compress.Config{})(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = io.WriteString(w, "data: 1\n\n")
+
+			f, ok := w.(http.Flusher)
+			assert.True(t, ok)
+			f.Flush()
+		}))
+
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.True(t, rec.Flushed)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "data: 1\n\n", decode(t, "gzip", rec.Body))
+}
+
+func TestCompress_NoBody(t *testing.T) {
+	h := compress.New(compress.Config{})(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, 0, rec.Body.Len())
+}
+
+func TestCompress_InvalidLevel(t *testing.T) {
+	assert.Panics(t, func() {
+		compress.New(compress.Config{Level: 42})
+	})
+}
+
+func decode(t *testing.T, encoding string, body io.Reader) string {
+	var (
+		r   io.Reader
+		err error
+	)
+
+	switch encoding {
+	case "gzip":
+		r, err = gzip.NewReader(body)
+	case "deflate":
+		r, err = zlib.NewReader(body)
+	default:
+		r = body
+	}
+
+	assert.NoError(t, err)
+
+	data, err := io.ReadAll(r)
+	assert.NoError(t, err)
+
+	return string(data)
+}
@@ -0,0 +1,276 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/snobb/susanin/pkg/middleware/response"
+)
+
+const (
+	// DefaultQueueTimeout is the default maximum time a request waits in the queue.
+	DefaultQueueTimeout = time.Second
+
+	// DefaultRetryAfter is the default Retry-After value of the shed requests.
+	DefaultRetryAfter = time.Second
+)
+
+// ErrOverloaded is the error returned to the client when the request is shed.
+var ErrOverloaded = errors.New("server is overloaded")
+
+var errTimeout = errors.New("queue timeout")
+
+// Priority is the request priority class. Higher priority requests are admitted from the
+// queue first and can push the lower priority ones out of a full queue.
+type Priority int
+
+// Priority classes
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+	// PriorityCritical requests (e.g. health checks) are always admitted.
+	PriorityCritical
+)
+
+// PriorityFunc returns the priority class of the request.
+type PriorityFunc func(r *http.Request) Priority
+
+// ByPath assigns the priority classes by the exact request path. The requests to other paths
+// get the default priority.
+func ByPath(paths map[string]Priority, def Priority) PriorityFunc {
+	return func(r *http.Request) Priority {
+		if prio, ok := paths[r.URL.Path]; ok {
+			return prio
+		}
+
+		return def
+	}
+}
+
+// Config is the concurrency limiter configuration.
+type Config struct {
+	// MaxInFlight is the maximum number of requests served concurrently.
+	MaxInFlight int
+
+	// QueueSize is the maximum number of requests waiting for a slot. Zero value disables
+	// the queue, so the requests over the limit are shed immediately.
+	QueueSize int
+
+	// QueueTimeout is the maximum time a request waits in the queue. Zero value means
+	// DefaultQueueTimeout.
+	QueueTimeout time.Duration
+
+	// RetryAfter is the Retry-After header value of the shed requests. Zero value means
+	// DefaultRetryAfter.
+	RetryAfter time.Duration
+
+	// Priority returns the request priority class. Zero value means PriorityNormal for all the
+	// requests.
+	Priority PriorityFunc
+}
+
+// Stats are the limiter counters.
+type Stats struct {
+	InFlight int    // requests being served
+	Queued   int    // requests waiting in the queue
+	Admitted uint64 // total number of admitted requests
+	Shed     uint64 // total number of requests rejected because the queue was full
+	TimedOut uint64 // total number of requests rejected after waiting in the queue
+}
+
+// Limiter caps the number of in-flight requests with a bounded priority queue.
+type Limiter struct {
+	cfg    Config
+	mu     sync.Mutex
+	queues [PriorityCritical][]*waiter
+	stats  Stats
+}
+
+type waiter struct {
+	ch       chan struct{}
+	prio     Priority
+	done     bool
+	admitted bool
+}
+
+// New creates a new concurrency Limiter. A single limiter can be attached globally with
+// Framework.Attach and separate ones per group of routes with framework.Use.
+// The function panics if MaxInFlight is not positive.
+func New(cfg Config) *Limiter {
+	if cfg.MaxInFlight <= 0 {
+		panic("concurrency: MaxInFlight must be positive")
+	}
+
+	if cfg.QueueTimeout <= 0 {
+		cfg.QueueTimeout = DefaultQueueTimeout
+	}
+
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = DefaultRetryAfter
+	}
+
+	if cfg.Priority == nil {
+		cfg.Priority = func(*http.Request) Priority { return PriorityNormal }
+	}
+
+	return &Limiter{cfg: cfg}
+}
+
+// Middleware is the limiter middleware function. The shed requests are responded with
+// HTTP 503 and Retry-After header.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := l.acquire(r.Context(), l.cfg.Priority(r)); err != nil {
+			if r.Context().Err() != nil {
+				// the client has gone away
+				return
+			}
+
+			retry := int(math.Ceil(l.cfg.RetryAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(retry))
+			_ = response.New(w).Error(r.Context(), http.StatusServiceUnavailable, ErrOverloaded)
+			return
+		}
+
+		defer l.release()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Stats returns the current values of the limiter counters.
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.stats
+}
+
+func (l *Limiter) acquire(ctx context.Context, prio Priority) error {
+	if prio > PriorityCritical {
+		prio = PriorityCritical
+	} else if prio < PriorityLow {
+		prio = PriorityLow
+	}
+
+	l.mu.Lock()
+
+	if prio == PriorityCritical || (l.stats.InFlight < l.cfg.MaxInFlight && l.stats.Queued == 0) {
+		l.stats.InFlight++
+		l.stats.Admitted++
+		l.mu.Unlock()
+		return nil
+	}
+
+	if l.stats.Queued >= l.cfg.QueueSize && !l.shedLowerLocked(prio) {
+		l.stats.Shed++
+		l.mu.Unlock()
+		return ErrOverloaded
+	}
+
+	w := &waiter{ch: make(chan struct{}), prio: prio}
+	l.queues[prio] = append(l.queues[prio], w)
+	l.stats.Queued++
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.cfg.QueueTimeout)
+	defer timer.Stop()
+
+	var err error
+
+	select {
+	case <-w.ch:
+	case <-timer.C:
+		err = errTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if w.done {
+		// the waiter was signalled concurrently with the timeout.
+		if w.admitted {
+			return nil
+		}
+
+		return ErrOverloaded
+	}
+
+	l.removeLocked(w)
+	if errors.Is(err, errTimeout) {
+		l.stats.TimedOut++
+	}
+
+	return err
+}
+
+func (l *Limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stats.InFlight--
+
+	for l.stats.InFlight < l.cfg.MaxInFlight && l.stats.Queued > 0 {
+		w := l.popLocked()
+		w.done, w.admitted = true, true
+		l.stats.InFlight++
+		l.stats.Admitted++
+		close(w.ch)
+	}
+}
+
+// popLocked removes the oldest waiter of the highest priority.
+func (l *Limiter) popLocked() *waiter {
+	for prio := len(l.queues) - 1; prio >= 0; prio-- {
+		if q := l.queues[prio]; len(q) > 0 {
+			w := q[0]
+			q[0] = nil
+			l.queues[prio] = q[1:]
+			l.stats.Queued--
+			return w
+		}
+	}
+
+	return nil
+}
+
+// shedLowerLocked rejects the newest waiter with priority lower than prio to make room in
+// the queue.
+func (l *Limiter) shedLowerLocked(prio Priority) bool {
+	for p := PriorityLow; p < prio; p++ {
+		q := l.queues[p]
+		if len(q) == 0 {
+			continue
+		}
+
+		w := q[len(q)-1]
+		l.queues[p] = q[:len(q)-1]
+		l.stats.Queued--
+		l.stats.Shed++
+		w.done = true
+		close(w.ch)
+
+		return true
+	}
+
+	return false
+}
+
+func (l *Limiter) removeLocked(w *waiter) {
+	q := l.queues[w.prio]
+
+	for i := range q {
+		if q[i] == w {
+			l.queues[w.prio] = append(q[:i], q[i+1:]...)
+			l.stats.Queued--
+			return
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package concurrency_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/middleware/concurrency"
+)
+
+// blocking returns a handler that blocks until the release channel is closed.
+func blocking(started chan<- string, release <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- r.URL.Path
+		<-release
+		w.WriteHeader(200)
+	})
+}
+
+func serve(h http.Handler, path string) <-chan int {
+	code := make(chan int, 1)
+
+	go func() {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		code <- rec.Code
+	}()
+
+	return code
+}
+
+func waitFor(t *testing.T, l *concurrency.Limiter, cond func(concurrency.Stats) bool) {
+	deadline := time.Now().Add(time.Second)
+	for !cond(l.Stats()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition is not met: %+v", l.Stats())
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLimiter_Shed(t *testing.T) {
+	started := make(chan string, 10)
+	release := make(chan struct{})
+
+	l := concurrency.New(concurrency.Config{
+		MaxInFlight: 1,
+		Priority: concurrency.ByPath(map[string]concurrency.Priority{
+			"/healthz": concurrency.PriorityCritical,
+		}, concurrency.PriorityNormal),
+	})
+	h := l.Middleware(blocking(started, release))
+
+	first := serve(h, "/work")
+	assert.Equal(t, "/work", <-started)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/work", nil))
+	assert.Equal(t, 503, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	assert.JSONEq(t,
+		`{"code":503,"error":"server is overloaded","message":"Service Unavailable"}`,
+		rec.Body.String())
+
+	// health checks are always admitted
+	health := serve(h, "/healthz")
+	assert.Equal(t, "/healthz", <-started)
+
+	close(release)
+	assert.Equal(t, 200, <-first)
+	assert.Equal(t, 200, <-health)
+
+	assert.Equal(t, concurrency.Stats{Admitted: 2, Shed: 1}, l.Stats())
+}
+
+func TestLimiter_Queue(t *testing.T) {
+	started := make(chan string, 10)
+	release := make(chan struct{})
+
+	l := concurrency.New(concurrency.Config{
+		MaxInFlight:  1,
+		QueueSize:    2,
+		QueueTimeout: time.Second,
+		Priority: concurrency.ByPath(map[string]concurrency.Priority{
+			"/low":  concurrency.PriorityLow,
+			"/high": concurrency.PriorityHigh,
+		}, concurrency.PriorityNormal),
+	})
+	h := l.Middleware(blocking(started, release))
+
+	first := serve(h, "/normal")
+	assert.Equal(t, "/normal", <-started)
+
+	low := serve(h, "/low")
+	waitFor(t, l, func(s concurrency.Stats) bool { return s.Queued == 1 })
+	normal := serve(h, "/normal")
+	waitFor(t, l, func(s concurrency.Stats) bool { return s.Queued == 2 })
+
+	// the queue is full, so the low priority request is pushed out
+	high := serve(h, "/high")
+	assert.Equal(t, 503, <-low)
+	waitFor(t, l, func(s concurrency.Stats) bool { return s.Queued == 2 })
+
+	// the queue is full with equal or higher priority requests
+	assert.Equal(t, 503, <-serve(h, "/low"))
+
+	close(release)
+	assert.Equal(t, 200, <-first)
+	assert.Equal(t, "/high", <-started) // high priority is admitted first
+	assert.Equal(t, "/normal", <-started)
+	assert.Equal(t, 200, <-high)
+	assert.Equal(t, 200, <-normal)
+
+	assert.Equal(t, concurrency.Stats{Admitted: 3, Shed: 2}, l.Stats())
+}
+
+func TestLimiter_QueueTimeout(t *testing.T) {
+	started := make(chan string, 10)
+	release := make(chan struct{})
+
+	l := concurrency.New(concurrency.Config{
+		MaxInFlight:  1,
+		QueueSize:    1,
+		QueueTimeout: 10 * time.Millisecond,
+	})
+	h := l.Middleware(blocking(started, release))
+
+	first := serve(h, "/")
+	<-started
+
+	assert.Equal(t, 503, <-serve(h, "/"))
+
+	close(release)
+	assert.Equal(t, 200, <-first)
+	assert.Equal(t, concurrency.Stats{Admitted: 1, TimedOut: 1}, l.Stats())
+}
+
+func TestLimiter_Concurrent(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		inFlight int
+		maxSeen  int
+	)
+
+	l := concurrency.New(concurrency.Config{MaxInFlight: 3, QueueSize: 100})
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxSeen {
+			maxSeen = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+
+	var codes []<-chan int
+	for i := 0; i < 50; i++ {
+		codes = append(codes, serve(h, "/"))
+	}
+
+	for _, code := range codes {
+		assert.Equal(t, 200, <-code)
+	}
+
+	assert.LessOrEqual(t, maxSeen, 3)
+	assert.Equal(t, uint64(50), l.Stats().Admitted)
+}
+
+func TestLimiter_Invalid(t *testing.T) {
+	assert.Panics(t, func() {
+		concurrency.New(concurrency.Config{})
+	})
+}
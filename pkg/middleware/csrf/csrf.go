@@ -0,0 +1,395 @@
+package csrf
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/snobb/susanin/pkg/middleware"
+	"github.com/snobb/susanin/pkg/middleware/proxy"
+	"github.com/snobb/susanin/pkg/middleware/response"
+	"github.com/snobb/susanin/pkg/middleware/session"
+)
+
+type stateKey struct{}
+
+const (
+	// DefaultCookie is the default name of the double submit cookie.
+	DefaultCookie = "_csrf"
+
+	// DefaultHeader is the default request header carrying the token.
+	DefaultHeader = "X-CSRF-Token"
+
+	// DefaultField is the default form field carrying the token.
+	DefaultField = "csrf_token"
+
+	// sessionKey is the session key the synchronizer token is kept under.
+	sessionKey = "_csrf"
+
+	tokenSize = 32
+)
+
+var (
+	// ErrBadOrigin is returned if the Origin or Referer header of an unsafe request does not
+	// match the request scheme and host or the trusted origins.
+	ErrBadOrigin = errors.New("CSRF origin check failed")
+
+	// ErrNoToken is returned if the unsafe request or the client state has no token.
+	ErrNoToken = errors.New("CSRF token is missing")
+
+	// ErrBadToken is returned if the submitted token does not match the expected one.
+	ErrBadToken = errors.New("CSRF token is invalid")
+)
+
+// Mode is the token storage mode.
+type Mode int
+
+// Token storage modes
+const (
+	// DoubleSubmit keeps the token in a cookie and expects the same token to be submitted
+	// with the request.
+	DoubleSubmit Mode = iota
+
+	// Synchronizer keeps the token in the session. The session middleware must be installed
+	// before the CSRF middleware.
+	Synchronizer
+)
+
+// Config is the CSRF middleware configuration.
+type Config struct {
+	// Mode is the token storage mode. Zero value means DoubleSubmit.
+	Mode Mode
+
+	// Cookie is the name of the double submit cookie. Zero value means DefaultCookie.
+	Cookie string
+
+	// Header is the request header carrying the token. Zero value means DefaultHeader.
+	Header string
+
+	// Field is the form field carrying the token. Zero value means DefaultField.
+	Field string
+
+	// Secure restricts the double submit cookie to HTTPS.
+	Secure bool
+
+	// TrustedOrigins is a list of the origins, e.g. "https://admin.example.com", allowed to
+	// send unsafe requests in addition to the request host.
+	TrustedOrigins []string
+
+	// Exempt is a list of the request paths that are not protected. The paths ending with "*"
+	// match as prefixes, e.g. "/webhooks/*".
+	Exempt []string
+}
+
+type protector struct {
+	cfg     Config
+	trusted map[string]bool
+}
+
+// New creates a CSRF protection middleware. The unsafe requests, i.e. not GET, HEAD, OPTIONS
+// or TRACE, must have a matching Origin (or Referer) and carry the token in the header or the
+// form field. The failed requests are responded with HTTP 403. The token for the templates is
+// available with Token and Field.
+func New(cfg Config) middleware.Middleware {
+	if cfg.Cookie == "" {
+		cfg.Cookie = DefaultCookie
+	}
+
+	if cfg.Header == "" {
+		cfg.Header = DefaultHeader
+	}
+
+	if cfg.Field == "" {
+		cfg.Field = DefaultField
+	}
+
+	p := &protector{cfg: cfg, trusted: make(map[string]bool)}
+	for _, origin := range cfg.TrustedOrigins {
+		p.trusted[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if p.exempt(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			st := &state{p: p, w: w, r: r}
+			r = r.WithContext(context.WithValue(r.Context(), stateKey{}, st))
+			st.r = r
+
+			if !safeMethod(r.Method) {
+				if err := p.verify(r); err != nil {
+					_ = response.New(w).Error(r.Context(), http.StatusForbidden, err)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Token returns the masked token for the forms or the headers of the subsequent requests.
+// The token is generated on the first call, so it must be called before the response is
+// written. The token is masked with a random pad on every call to prevent BREACH attacks.
+// An empty string is returned if the middleware is not installed.
+func Token(ctx context.Context) string {
+	st, ok := ctx.Value(stateKey{}).(*state)
+	if !ok {
+		return ""
+	}
+
+	token, err := st.token()
+	if err != nil {
+		return ""
+	}
+
+	return mask(token)
+}
+
+// Field returns the hidden form input with the token for the HTML templates.
+func Field(ctx context.Context) template.HTML {
+	st, ok := ctx.Value(stateKey{}).(*state)
+	if !ok {
+		return ""
+	}
+
+	return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(st.p.cfg.Field) +
+		`" value="` + Token(ctx) + `">`)
+}
+
+func (p *protector) exempt(path string) bool {
+	for _, ex := range p.cfg.Exempt {
+		if strings.HasSuffix(ex, "*") {
+			if strings.HasPrefix(path, ex[:len(ex)-1]) {
+				return true
+			}
+		} else if path == ex {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verify checks the origin and the token of the unsafe request.
+func (p *protector) verify(r *http.Request) error {
+	if err := p.checkOrigin(r); err != nil {
+		return err
+	}
+
+	expected, err := p.load(r)
+	if err != nil {
+		return err
+	}
+
+	if expected == nil {
+		return ErrNoToken
+	}
+
+	submitted := r.Header.Get(p.cfg.Header)
+	if submitted == "" {
+		submitted = r.PostFormValue(p.cfg.Field)
+	}
+
+	if submitted == "" {
+		return ErrNoToken
+	}
+
+	token, ok := unmask(submitted)
+	if !ok || subtle.ConstantTimeCompare(token, expected) != 1 {
+		return ErrBadToken
+	}
+
+	return nil
+}
+
+// checkOrigin checks the Origin header or the Referer if there is no Origin. The source must
+// have both the scheme and the host of the request unless it is trusted. The requests
+// without both of them are allowed over plain HTTP only, as the browsers strip the Referer
+// when going from HTTPS to HTTP. The scheme and the host resolved by the proxy middleware are
+// used if it is installed.
+func (p *protector) checkOrigin(r *http.Request) error {
+	source := r.Header.Get("Origin")
+	if source == "" {
+		source = r.Header.Get("Referer")
+		if source == "" {
+			if isHTTPS(r) {
+				return ErrBadOrigin
+			}
+
+			return nil
+		}
+	}
+
+	u, err := url.Parse(source)
+	if err != nil || u.Host == "" {
+		return ErrBadOrigin
+	}
+
+	host, ok := proxy.Host(r.Context())
+	if !ok {
+		host = r.Host
+	}
+
+	scheme := "http"
+	if isHTTPS(r) {
+		scheme = "https"
+	}
+
+	// the same host over another scheme, e.g. a plain HTTP attacker, is not the same origin
+	if strings.EqualFold(u.Host, host) && strings.EqualFold(u.Scheme, scheme) {
+		return nil
+	}
+
+	if p.trusted[strings.ToLower(u.Scheme+"://"+u.Host)] {
+		return nil
+	}
+
+	return ErrBadOrigin
+}
+
+// isHTTPS reports if the client uses HTTPS. The scheme resolved by the proxy middleware is
+// used if it is installed.
+func isHTTPS(r *http.Request) bool {
+	if scheme, ok := proxy.Scheme(r.Context()); ok {
+		return scheme == "https"
+	}
+
+	return r.TLS != nil
+}
+
+// load returns the current token of the client or nil.
+func (p *protector) load(r *http.Request) ([]byte, error) {
+	var encoded string
+
+	if p.cfg.Mode == Synchronizer {
+		s, err := session.Session(r.Context())
+		if err != nil {
+			return nil, err
+		}
+
+		encoded = s.GetString(sessionKey)
+	} else if c, err := r.Cookie(p.cfg.Cookie); err == nil {
+		encoded = c.Value
+	}
+
+	token, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(token) != tokenSize {
+		return nil, nil
+	}
+
+	return token, nil
+}
+
+// state is the per request token state.
+type state struct {
+	p *protector
+	w http.ResponseWriter
+	r *http.Request
+
+	mu  sync.Mutex
+	tok []byte
+}
+
+// token returns the client token generating a new one if needed.
+func (st *state) token() ([]byte, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.tok != nil {
+		return st.tok, nil
+	}
+
+	tok, err := st.p.load(st.r)
+	if err != nil {
+		return nil, err
+	}
+
+	if tok == nil {
+		tok = make([]byte, tokenSize)
+		if _, err := rand.Read(tok); err != nil {
+			return nil, err
+		}
+
+		if err := st.store(tok); err != nil {
+			return nil, err
+		}
+	}
+
+	st.tok = tok
+	return tok, nil
+}
+
+// store saves the new token in the session or the cookie.
+func (st *state) store(tok []byte) error {
+	encoded := base64.RawURLEncoding.EncodeToString(tok)
+
+	if st.p.cfg.Mode == Synchronizer {
+		s, err := session.Session(st.r.Context())
+		if err != nil {
+			return err
+		}
+
+		s.Set(sessionKey, encoded)
+		return nil
+	}
+
+	http.SetCookie(st.w, &http.Cookie{
+		Name:     st.p.cfg.Cookie,
+		Value:    encoded,
+		Path:     "/",
+		Secure:   st.p.cfg.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return nil
+}
+
+// mask masks the token with a random one time pad.
+func mask(token []byte) string {
+	buf := make([]byte, 2*len(token))
+	if _, err := rand.Read(buf[:len(token)]); err != nil {
+		return ""
+	}
+
+	for i, b := range token {
+		buf[len(token)+i] = b ^ buf[i]
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// unmask recovers the token from the masked one.
+func unmask(masked string) ([]byte, bool) {
+	buf, err := base64.RawURLEncoding.DecodeString(masked)
+	if err != nil || len(buf) != 2*tokenSize {
+		return nil, false
+	}
+
+	token := make([]byte, tokenSize)
+	for i := range token {
+		token[i] = buf[i] ^ buf[tokenSize+i]
+	}
+
+	return token, true
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,277 @@
+package csrf_test
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/middleware/csrf"
+	"github.com/snobb/susanin/pkg/middleware/proxy"
+	"github.com/snobb/susanin/pkg/middleware/session"
+)
+
+func handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(csrf.Token(r.Context())))
+	})
+}
+
+// fetch performs a GET request and returns the token and the cookies of the response.
+func fetch(h http.Handler, cookies []*http.Cookie) (string, []*http.Cookie) {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/form", nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	return rec.Body.String(), rec.Result().Cookies()
+}
+
+func TestCSRF_DoubleSubmit(t *testing.T) {
+	h := csrf.New(csrf.Config{
+		TrustedOrigins: []string{"https://admin.example.com"},
+		Exempt:         []string{"/webhooks/*", "/login"},
+	})(handler())
+
+	token, cookies := fetch(h, nil)
+	assert.Len(t, cookies, 1)
+	assert.True(t, cookies[0].HttpOnly)
+
+	again, more := fetch(h, cookies)
+	assert.NotEqual(t, token, again, "token must be masked differently on every request")
+	assert.Empty(t, more, "existing token must be reused")
+
+	tests := map[string]struct {
+		path     string
+		header   map[string]string
+		form     url.Values
+		noCookie bool
+		tls      bool
+		wantCode int
+		wantErr  error
+	}{
+		"should accept token in the header": {
+			header:   map[string]string{"X-CSRF-Token": token},
+			wantCode: 200,
+		},
+		"should accept another masking of the token": {
+			header:   map[string]string{"X-CSRF-Token": again},
+			wantCode: 200,
+		},
+		"should accept token in the form": {
+			form:     url.Values{"csrf_token": {token}},
+			wantCode: 200,
+		},
+		"should accept matching origin": {
+			header:   map[string]string{"X-CSRF-Token": token, "Origin": "http://example.com"},
+			wantCode: 200,
+		},
+		"should accept trusted origin": {
+			header: map[string]string{
+				"X-CSRF-Token": token,
+				"Origin":       "https://admin.example.com",
+			},
+			wantCode: 200,
+		},
+		"should accept matching referer": {
+			header: map[string]string{
+				"X-CSRF-Token": token,
+				"Referer":      "http://example.com/form",
+			},
+			wantCode: 200,
+		},
+		"should reject foreign origin": {
+			header:   map[string]string{"X-CSRF-Token": token, "Origin": "http://evil.com"},
+			wantCode: 403,
+			wantErr:  csrf.ErrBadOrigin,
+		},
+		"should reject foreign referer": {
+			header:   map[string]string{"X-CSRF-Token": token, "Referer": "http://evil.com/x"},
+			wantCode: 403,
+			wantErr:  csrf.ErrBadOrigin,
+		},
+		"should accept matching origin over HTTPS": {
+			header:   map[string]string{"X-CSRF-Token": token, "Origin": "https://example.com"},
+			tls:      true,
+			wantCode: 200,
+		},
+		"should reject HTTP origin of HTTPS request": {
+			header:   map[string]string{"X-CSRF-Token": token, "Origin": "http://example.com"},
+			tls:      true,
+			wantCode: 403,
+			wantErr:  csrf.ErrBadOrigin,
+		},
+		"should reject HTTP referer of HTTPS request": {
+			header: map[string]string{
+				"X-CSRF-Token": token,
+				"Referer":      "http://example.com/form",
+			},
+			tls:      true,
+			wantCode: 403,
+			wantErr:  csrf.ErrBadOrigin,
+		},
+		"should reject HTTPS request without origin and referer": {
+			header:   map[string]string{"X-CSRF-Token": token},
+			tls:      true,
+			wantCode: 403,
+			wantErr:  csrf.ErrBadOrigin,
+		},
+		"should reject request without token": {
+			wantCode: 403,
+			wantErr:  csrf.ErrNoToken,
+		},
+		"should reject request without cookie": {
+			header:   map[string]string{"X-CSRF-Token": token},
+			noCookie: true,
+			wantCode: 403,
+			wantErr:  csrf.ErrNoToken,
+		},
+		"should reject invalid token": {
+			header:   map[string]string{"X-CSRF-Token": strings.ToUpper(token)},
+			wantCode: 403,
+			wantErr:  csrf.ErrBadToken,
+		},
+		"should skip exempt prefix": {
+			path:     "/webhooks/github",
+			noCookie: true,
+			wantCode: 200,
+		},
+		"should skip exempt path": {
+			path:     "/login",
+			noCookie: true,
+			wantCode: 200,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := tt.path
+			if path == "" {
+				path = "/form"
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "http://example.com"+path,
+				strings.NewReader(tt.form.Encode()))
+			if tt.form != nil {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+
+			if !tt.noCookie {
+				req.AddCookie(cookies[0])
+			}
+
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+
+			if tt.wantErr != nil {
+				var body map[string]interface{}
+				assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+				assert.Equal(t, tt.wantErr.Error(), body["error"])
+			}
+		})
+	}
+}
+
+func TestCSRF_Proxy(t *testing.T) {
+	h := proxy.New(proxy.Config{TrustedProxies: []string{"192.0.2.1"}})(
+		csrf.New(csrf.Config{})(handler()))
+
+	token, cookies := fetch(h, nil)
+
+	tests := map[string]struct {
+		origin   string
+		wantCode int
+	}{
+		"should reject HTTPS request without origin and referer behind the proxy": {
+			wantCode: 403,
+		},
+		"should accept the origin of the forwarded host": {
+			origin:   "https://example.com",
+			wantCode: 200,
+		},
+		"should reject the HTTP origin of the forwarded host": {
+			origin:   "http://example.com",
+			wantCode: 403,
+		},
+		"should reject the origin of the proxied host": {
+			origin:   "https://backend:8080",
+			wantCode: 403,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "http://backend:8080/form", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			req.Header.Set("X-Forwarded-For", "203.0.113.9")
+			req.Header.Set("X-Forwarded-Proto", "https")
+			req.Header.Set("X-Forwarded-Host", "example.com")
+			req.Header.Set("X-CSRF-Token", token)
+			req.AddCookie(cookies[0])
+
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+		})
+	}
+}
+
+func TestCSRF_Synchronizer(t *testing.T) {
+	h := session.New(session.Config{Keys: []session.Key{{Sign: []byte("key")}}})(
+		csrf.New(csrf.Config{Mode: csrf.Synchronizer})(handler()))
+
+	token, cookies := fetch(h, nil)
+	assert.Len(t, cookies, 1)
+	assert.Equal(t, session.DefaultName, cookies[0].Name, "token must be kept in the session")
+
+	post := func(token string, cookies []*http.Cookie) int {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/form", nil)
+		req.Header.Set("X-CSRF-Token", token)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, 200, post(token, cookies))
+
+	other, _ := fetch(h, nil)
+	assert.Equal(t, 403, post(other, cookies), "token of another session")
+	assert.Equal(t, 403, post(token, nil), "no session")
+}
+
+func TestField(t *testing.T) {
+	h := csrf.New(csrf.Config{Field: "token"})(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(csrf.Field(r.Context())))
+		}))
+
+	field, _ := fetch(h, nil)
+	assert.Regexp(t, `^<input type="hidden" name="token" value="[\w-]{86}">$`, field)
+}
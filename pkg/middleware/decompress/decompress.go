@@ -0,0 +1,140 @@
+package decompress
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/snobb/susanin/pkg/middleware"
+	"github.com/snobb/susanin/pkg/middleware/response"
+)
+
+// DefaultMaxSize is the default limit of the decompressed request body size.
+const DefaultMaxSize = 10 << 20
+
+// Config is the request decompression middleware configuration.
+type Config struct {
+	// MaxSize is the maximum size of the decompressed request body in bytes. Zero value means
+	// DefaultMaxSize.
+	MaxSize int64
+}
+
+// New creates a middleware that transparently decodes gzip and deflate encoded request bodies.
+// Requests with unsupported encodings are rejected with HTTP 415. If the decompressed body
+// exceeds MaxSize, reading it fails with *http.MaxBytesError and, unless the handler has
+// already started responding, the response is replaced with HTTP 413.
+func New(cfg Config) middleware.Middleware {
+	maxSize := cfg.MaxSize
+	if maxSize == 0 {
+		maxSize = DefaultMaxSize
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encodings := parseEncodings(r.Header.Get("Content-Encoding"))
+			if len(encodings) == 0 || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			for _, enc := range encodings {
+				if enc != "gzip" && enc != "x-gzip" && enc != "deflate" {
+					w.Header().Set("Accept-Encoding", "gzip, deflate")
+					_ = response.New(w).Error(r.Context(), http.StatusUnsupportedMediaType,
+						fmt.Errorf("unsupported content encoding: %s", enc))
+					return
+				}
+			}
+
+			var body io.Reader = r.Body
+			closers := []io.Closer{r.Body}
+
+			// encodings are listed in the order they were applied.
+			for i := len(encodings) - 1; i >= 0; i-- {
+				rc, err := newDecoder(encodings[i], body)
+				if err != nil {
+					_ = response.New(w).Error(r.Context(), http.StatusBadRequest,
+						fmt.Errorf("invalid %s request body: %w", encodings[i], err))
+					return
+				}
+
+				body = rc
+				closers = append(closers, rc)
+			}
+
+			lw, lb := middleware.LimitBody(w, &multiCloser{body, closers}, maxSize,
+				func(w http.ResponseWriter) {
+					_ = response.New(w).Error(r.Context(), http.StatusRequestEntityTooLarge,
+						errors.New("decompressed request body is too large"))
+				})
+
+			r2 := r.Clone(r.Context())
+			r2.Body = lb
+			r2.ContentLength = -1
+			r2.Header.Del("Content-Encoding")
+			r2.Header.Del("Content-Length")
+
+			next.ServeHTTP(lw, r2)
+		})
+	}
+}
+
+// parseEncodings returns the list of non-identity encodings from the Content-Encoding header.
+func parseEncodings(header string) []string {
+	var encodings []string
+
+	for _, enc := range strings.Split(header, ",") {
+		enc = strings.ToLower(strings.TrimSpace(enc))
+		if enc == "" || enc == "identity" {
+			continue
+		}
+
+		encodings = append(encodings, enc)
+	}
+
+	return encodings
+}
+
+// newDecoder creates a decoder for the encoding. Deflate accepts both zlib wrapped (as per
+// RFC 9110) and raw deflate streams sent by some clients.
+func newDecoder(encoding string, r io.Reader) (io.ReadCloser, error) {
+	if encoding != "deflate" {
+		return gzip.NewReader(r)
+	}
+
+	br := bufio.NewReader(r)
+
+	hdr, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+
+	if hdr[0]&0x0f == 8 && (uint16(hdr[0])<<8|uint16(hdr[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+
+	return flate.NewReader(br), nil
+}
+
+// multiCloser closes the decoders and the original request body.
+type multiCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (mc *multiCloser) Close() error {
+	var err error
+	for i := len(mc.closers) - 1; i >= 0; i-- {
+		if cerr := mc.closers[i].Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
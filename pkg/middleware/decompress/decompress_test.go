@@ -0,0 +1,130 @@
+package decompress_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/middleware/decompress"
+)
+
+func TestDecompress_New(t *testing.T) {
+	payload := `{"name":"susanin"}`
+
+	tests := map[string]struct {
+		encoding string
+		body     []byte
+		maxSize  int64
+		wantCode int
+		wantBody string
+	}{
+		"should pass through not encoded body": {
+			body:     []byte(payload),
+			wantCode: 200,
+			wantBody: payload,
+		},
+		"should decode gzip body": {
+			encoding: "gzip",
+			body:     gzipData(payload),
+			wantCode: 200,
+			wantBody: payload,
+		},
+		"should decode zlib wrapped deflate body": {
+			encoding: "deflate",
+			body:     zlibData(payload),
+			wantCode: 200,
+			wantBody: payload,
+		},
+		"should decode raw deflate body": {
+			encoding: "Deflate",
+			body:     flateData(payload),
+			wantCode: 200,
+			wantBody: payload,
+		},
+		"should decode multiple encodings in reverse order": {
+			encoding: "deflate, gzip",
+			body:     gzipData(string(zlibData(payload))),
+			wantCode: 200,
+			wantBody: payload,
+		},
+		"should reject unsupported encoding with 415": {
+			encoding: "br",
+			body:     []byte(payload),
+			wantCode: 415,
+			wantBody: `{"code":415,"error":"unsupported content encoding: br","message":"Unsupported Media Type"}`,
+		},
+		"should reject invalid gzip body with 400": {
+			encoding: "gzip",
+			body:     []byte(payload),
+			wantCode: 400,
+			wantBody: `{"code":400,"error":"invalid gzip request body: gzip: invalid header","message":"Bad Request"}`,
+		},
+		"should reject too large decompressed body with 413": {
+			encoding: "gzip",
+			body:     gzipData(strings.Repeat("0", 4096)),
+			maxSize:  1024,
+			wantCode: 413,
+			wantBody: `{"code":413,"error":"decompressed request body is too large","message":"Request Entity Too Large"}`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := decompress.New(decompress.Config{MaxSize: tt.maxSize})(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					assert.Empty(t, r.Header.Get("Content-Encoding"))
+
+					body, err := io.ReadAll(r.Body)
+					if err != nil {
+						w.WriteHeader(500)
+						return
+					}
+
+					_, _ = w.Write(body)
+				}))
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.body))
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, tt.wantBody, strings.TrimSpace(rec.Body.String()))
+		})
+	}
+}
+
+func gzipData(s string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, _ = io.WriteString(w, s)
+	_ = w.Close()
+	return buf.Bytes()
+}
+
+func zlibData(s string) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	_, _ = io.WriteString(w, s)
+	_ = w.Close()
+	return buf.Bytes()
+}
+
+func flateData(s string) []byte {
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	_, _ = io.WriteString(w, s)
+	_ = w.Close()
+	return buf.Bytes()
+}
@@ -0,0 +1,120 @@
+package ipfilter
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ParseRules parses the rules file. Every line is either "allow <network>" or
+// "deny <network>". Empty lines and the lines starting with "#" are ignored.
+func ParseRules(data []byte) (Config, error) {
+	var cfg Config
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return Config{}, fmt.Errorf("line %d: invalid rule: %q", n, line)
+		}
+
+		switch strings.ToLower(fields[0]) {
+		case "allow":
+			cfg.Allow = append(cfg.Allow, fields[1])
+		case "deny":
+			cfg.Deny = append(cfg.Deny, fields[1])
+		default:
+			return Config{}, fmt.Errorf("line %d: invalid action: %q", n, fields[0])
+		}
+	}
+
+	return cfg, scanner.Err()
+}
+
+// File is a Filter with the rules loaded from a file.
+type File struct {
+	*Filter
+
+	path    string
+	mu      sync.Mutex
+	modTime time.Time
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewFile loads the rules file and reloads it every interval if it has been modified. Zero
+// interval disables the periodic reload. Failed reloads keep the current rules.
+func NewFile(path string, interval time.Duration) (*File, error) {
+	f := &File{Filter: &Filter{}, path: path, done: make(chan struct{})}
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+
+	if interval > 0 {
+		go f.watch(interval)
+	}
+
+	return f, nil
+}
+
+// Reload reloads the file if it has been modified since the last load.
+func (f *File) Reload() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+
+	if !f.modTime.IsZero() && info.ModTime().Equal(f.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := ParseRules(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.path, err)
+	}
+
+	if err := f.Update(cfg); err != nil {
+		return fmt.Errorf("%s: %w", f.path, err)
+	}
+
+	f.modTime = info.ModTime()
+	return nil
+}
+
+// Close stops the periodic reload.
+func (f *File) Close() {
+	f.once.Do(func() {
+		close(f.done)
+	})
+}
+
+func (f *File) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = f.Reload()
+		case <-f.done:
+			return
+		}
+	}
+}
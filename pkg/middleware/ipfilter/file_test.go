@@ -0,0 +1,64 @@
+package ipfilter_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/middleware/ipfilter"
+)
+
+func TestParseRules(t *testing.T) {
+	data := "# office\nallow 192.168.0.0/16\n\n  DENY 192.168.66.0/24 \n"
+
+	cfg, err := ipfilter.ParseRules([]byte(data))
+	assert.NoError(t, err)
+	assert.Equal(t, ipfilter.Config{
+		Allow: []string{"192.168.0.0/16"},
+		Deny:  []string{"192.168.66.0/24"},
+	}, cfg)
+
+	_, err = ipfilter.ParseRules([]byte("allow 10.0.0.0/8\npermit 10.0.0.1\n"))
+	assert.EqualError(t, err, `line 2: invalid action: "permit"`)
+
+	_, err = ipfilter.ParseRules([]byte("allow\n"))
+	assert.Error(t, err)
+}
+
+func TestFile_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules")
+	mtime := time.Now().Add(-time.Hour)
+
+	write := func(data string) {
+		mtime = mtime.Add(time.Second)
+		assert.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+		assert.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+
+	write("allow 10.0.0.0/8\n")
+
+	f, err := ipfilter.NewFile(path, 5*time.Millisecond)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	assert.True(t, f.Allowed(net.ParseIP("10.0.0.1")))
+	assert.False(t, f.Allowed(net.ParseIP("192.168.0.1")))
+
+	write("allow 192.168.0.0/16\n")
+
+	assert.Eventually(t, func() bool {
+		return f.Allowed(net.ParseIP("192.168.0.1")) && !f.Allowed(net.ParseIP("10.0.0.1"))
+	}, time.Second, 5*time.Millisecond)
+
+	// invalid files keep the current rules
+	write("allow 10.0.0.0/99\n")
+	assert.Error(t, f.Reload())
+	assert.True(t, f.Allowed(net.ParseIP("192.168.0.1")))
+
+	_, err = ipfilter.NewFile(filepath.Join(t.TempDir(), "missing"), 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,148 @@
+package ipfilter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/snobb/susanin/pkg/middleware/proxy"
+	"github.com/snobb/susanin/pkg/middleware/response"
+)
+
+// ErrForbidden is the error returned to the clients that are not allowed.
+var ErrForbidden = errors.New("access denied")
+
+// Config is the IP filter configuration. The entries are either CIDRs, e.g. "10.0.0.0/8" or
+// "2001:db8::/32", or single IP addresses.
+type Config struct {
+	// Allow is the list of the allowed networks. If empty, all the addresses that are not
+	// denied are allowed.
+	Allow []string
+
+	// Deny is the list of the denied networks. Deny takes precedence over Allow.
+	Deny []string
+}
+
+// rules are the compiled allow and deny lists.
+type rules struct {
+	allow4, allow6 trie
+	deny4, deny6   trie
+	hasAllow       bool
+}
+
+// Filter allows or denies the requests by the client IP address. The client IP is resolved
+// by the proxy middleware if it is installed, so the trusted X-Forwarded-For hops are taken
+// into account, otherwise the remote address of the connection is used. The rules can be
+// updated at runtime.
+type Filter struct {
+	rules atomic.Value // *rules
+}
+
+// New creates a new IP filter.
+// The function panics if the configuration has an invalid entry.
+func New(cfg Config) *Filter {
+	f := &Filter{}
+	if err := f.Update(cfg); err != nil {
+		panic(fmt.Sprintf("ipfilter: %v", err))
+	}
+
+	return f
+}
+
+// Update replaces the rules atomically. The current rules are kept if the configuration has
+// an invalid entry.
+func (f *Filter) Update(cfg Config) error {
+	rs := &rules{hasAllow: len(cfg.Allow) > 0}
+
+	for _, entry := range cfg.Allow {
+		if err := rs.add(&rs.allow4, &rs.allow6, entry); err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range cfg.Deny {
+		if err := rs.add(&rs.deny4, &rs.deny6, entry); err != nil {
+			return err
+		}
+	}
+
+	f.rules.Store(rs)
+	return nil
+}
+
+// Allowed reports if the address is allowed.
+func (f *Filter) Allowed(ip net.IP) bool {
+	rs := f.rules.Load().(*rules)
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return !rs.deny4.contains(ip4) && (!rs.hasAllow || rs.allow4.contains(ip4))
+	}
+
+	if ip = ip.To16(); ip == nil {
+		return false
+	}
+
+	return !rs.deny6.contains(ip) && (!rs.hasAllow || rs.allow6.contains(ip))
+}
+
+// Middleware responds with HTTP 403 to the requests from the addresses that are not allowed.
+// It can be attached globally or to a WithPrefix group with framework.Use.
+func (f *Filter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !f.Allowed(clientIP(r)) {
+			_ = response.New(w).Error(r.Context(), http.StatusForbidden, ErrForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (rs *rules) add(v4, v6 *trie, entry string) error {
+	entry = strings.TrimSpace(entry)
+
+	if !strings.Contains(entry, "/") {
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return fmt.Errorf("invalid address: %q", entry)
+		}
+
+		if ip4 := ip.To4(); ip4 != nil {
+			v4.insert(&net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)})
+		} else {
+			v6.insert(&net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)})
+		}
+
+		return nil
+	}
+
+	_, ipnet, err := net.ParseCIDR(entry)
+	if err != nil {
+		return fmt.Errorf("invalid network: %q", entry)
+	}
+
+	if len(ipnet.IP) == net.IPv4len {
+		v4.insert(ipnet)
+	} else {
+		v6.insert(ipnet)
+	}
+
+	return nil
+}
+
+// clientIP returns the client IP address or nil.
+func clientIP(r *http.Request) net.IP {
+	if ip, ok := proxy.ClientIP(r.Context()); ok {
+		return ip
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return net.ParseIP(host)
+}
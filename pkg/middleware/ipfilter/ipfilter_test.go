@@ -0,0 +1,178 @@
+package ipfilter_test
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/framework"
+	"github.com/snobb/susanin/pkg/middleware/ipfilter"
+	"github.com/snobb/susanin/pkg/middleware/proxy"
+	"github.com/snobb/susanin/test/helper"
+)
+
+func TestFilter_Allowed(t *testing.T) {
+	tests := map[string]struct {
+		cfg  ipfilter.Config
+		ip   string
+		want bool
+	}{
+		"should allow everything without rules": {
+			ip:   "203.0.113.7",
+			want: true,
+		},
+		"should allow address in allowed network": {
+			cfg:  ipfilter.Config{Allow: []string{"10.0.0.0/8", "192.168.1.0/24"}},
+			ip:   "192.168.1.77",
+			want: true,
+		},
+		"should deny address outside allowed networks": {
+			cfg: ipfilter.Config{Allow: []string{"10.0.0.0/8", "192.168.1.0/24"}},
+			ip:  "192.168.2.1",
+		},
+		"should deny address in denied network": {
+			cfg: ipfilter.Config{Deny: []string{"203.0.113.0/24"}},
+			ip:  "203.0.113.7",
+		},
+		"should prefer deny over allow": {
+			cfg: ipfilter.Config{Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.1.0.0/16"}},
+			ip:  "10.1.2.3",
+		},
+		"should match single addresses": {
+			cfg:  ipfilter.Config{Allow: []string{"10.0.0.1", "2001:db8::1"}},
+			ip:   "2001:db8::1",
+			want: true,
+		},
+		"should not match neighbour of single address": {
+			cfg: ipfilter.Config{Allow: []string{"10.0.0.1", "2001:db8::1"}},
+			ip:  "10.0.0.2",
+		},
+		"should match IPv6 networks": {
+			cfg:  ipfilter.Config{Allow: []string{"2001:db8::/32"}},
+			ip:   "2001:db8:ffff::1",
+			want: true,
+		},
+		"should not match IPv4 address with IPv6 network": {
+			cfg: ipfilter.Config{Allow: []string{"::/0"}},
+			ip:  "10.0.0.1",
+		},
+		"should match IPv4-mapped IPv6 address with IPv4 network": {
+			cfg:  ipfilter.Config{Allow: []string{"10.0.0.0/8"}},
+			ip:   "::ffff:10.0.0.1",
+			want: true,
+		},
+		"should match any address with zero prefix": {
+			cfg:  ipfilter.Config{Allow: []string{"0.0.0.0/0"}},
+			ip:   "8.8.8.8",
+			want: true,
+		},
+		"should merge nested networks": {
+			cfg:  ipfilter.Config{Allow: []string{"10.1.2.0/24", "10.0.0.0/8", "10.1.0.0/16"}},
+			ip:   "10.200.0.1",
+			want: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := ipfilter.New(tt.cfg)
+			assert.Equal(t, tt.want, f.Allowed(net.ParseIP(tt.ip)))
+		})
+	}
+}
+
+func TestFilter_ManyNetworks(t *testing.T) {
+	var cfg ipfilter.Config
+	for i := 0; i < 4096; i++ {
+		cfg.Allow = append(cfg.Allow, fmt.Sprintf("10.%d.%d.0/24", i/256, i%256))
+	}
+
+	f := ipfilter.New(cfg)
+	assert.True(t, f.Allowed(net.ParseIP("10.15.255.1")))
+	assert.False(t, f.Allowed(net.ParseIP("10.16.0.1")))
+}
+
+func TestFilter_InvalidConfig(t *testing.T) {
+	assert.Panics(t, func() { ipfilter.New(ipfilter.Config{Allow: []string{"10.0.0.0/40"}}) })
+	assert.Panics(t, func() { ipfilter.New(ipfilter.Config{Deny: []string{"localhost"}}) })
+
+	f := ipfilter.New(ipfilter.Config{Allow: []string{"10.0.0.0/8"}})
+	assert.Error(t, f.Update(ipfilter.Config{Allow: []string{"invalid"}}))
+	assert.True(t, f.Allowed(net.ParseIP("10.0.0.1")), "current rules must be kept")
+}
+
+func TestFilter_Middleware(t *testing.T) {
+	admin := ipfilter.New(ipfilter.Config{Allow: []string{"192.168.0.0/16", "fd00::/8"}})
+
+	fw := framework.New()
+	fw.Attach(proxy.New(proxy.Config{TrustedProxies: []string{"10.0.0.0/8"}}))
+	fw.Get("/", helper.HandlerFactory(200, "public"))
+	fw.WithPrefix("/admin", func() {
+		fw.Get("/users", helper.HandlerFactory(200, "admin"))
+	}, framework.Use(admin.Middleware))
+
+	tests := map[string]struct {
+		path       string
+		remoteAddr string
+		forwarded  string
+		wantCode   int
+	}{
+		"should allow public route from anywhere": {
+			path:       "/",
+			remoteAddr: "203.0.113.7:1234",
+			wantCode:   200,
+		},
+		"should allow admin route from office": {
+			path:       "/admin/users",
+			remoteAddr: "192.168.1.10:1234",
+			wantCode:   200,
+		},
+		"should allow admin route from VPN": {
+			path:       "/admin/users",
+			remoteAddr: "[fd12::1]:1234",
+			wantCode:   200,
+		},
+		"should deny admin route from outside": {
+			path:       "/admin/users",
+			remoteAddr: "203.0.113.7:1234",
+			wantCode:   403,
+		},
+		"should use the client IP forwarded by the trusted proxy": {
+			path:       "/admin/users",
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  "192.168.1.10",
+			wantCode:   200,
+		},
+		"should not trust the proxy address itself": {
+			path:       "/admin/users",
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  "203.0.113.7",
+			wantCode:   403,
+		},
+		"should ignore forwarded address from untrusted remote": {
+			path:       "/admin/users",
+			remoteAddr: "203.0.113.7:1234",
+			forwarded:  "192.168.1.10",
+			wantCode:   403,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+
+			rec := httptest.NewRecorder()
+			fw.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package ipfilter
+
+import (
+	"net"
+)
+
+// trie is a binary prefix trie of the network addresses. A lookup takes at most 32 steps for
+// IPv4 and 128 for IPv6 regardless of the number of the networks.
+type trie struct {
+	root node
+}
+
+type node struct {
+	children [2]*node
+	terminal bool // a network ends at the node
+}
+
+// insert adds the network. The IPv4 networks must be given in the 4 byte form.
+func (t *trie) insert(ipnet *net.IPNet) {
+	ones, _ := ipnet.Mask.Size()
+
+	n := &t.root
+	for i := 0; i < ones; i++ {
+		if n.terminal {
+			return // covered by a shorter prefix
+		}
+
+		b := bit(ipnet.IP, i)
+		if n.children[b] == nil {
+			n.children[b] = &node{}
+		}
+
+		n = n.children[b]
+	}
+
+	n.terminal = true
+	n.children = [2]*node{} // longer prefixes are covered now
+}
+
+// contains reports if the address belongs to any of the networks.
+func (t *trie) contains(ip net.IP) bool {
+	n := &t.root
+	for i := 0; i < 8*len(ip); i++ {
+		if n.terminal {
+			return true
+		}
+
+		if n = n.children[bit(ip, i)]; n == nil {
+			return false
+		}
+	}
+
+	return n.terminal
+}
+
+func bit(ip net.IP, i int) int {
+	return int(ip[i/8]>>(7-uint(i%8))) & 1
+}
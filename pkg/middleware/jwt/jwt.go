@@ -0,0 +1,295 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/snobb/susanin/pkg/middleware"
+	"github.com/snobb/susanin/pkg/middleware/auth"
+)
+
+var (
+	// ErrExpired is returned if the token has expired.
+	ErrExpired = errors.New("token has expired")
+
+	// ErrNotValidYet is returned if the token is used before its "nbf" time.
+	ErrNotValidYet = errors.New("token is not valid yet")
+
+	// ErrIssuedInFuture is returned if the token "iat" time is in the future.
+	ErrIssuedInFuture = errors.New("token is issued in the future")
+
+	// ErrIssuer is returned if the token issuer does not match.
+	ErrIssuer = errors.New("invalid token issuer")
+
+	// ErrAudience is returned if the token audience does not match.
+	ErrAudience = errors.New("invalid token audience")
+)
+
+// DefaultClockSkew is the default allowed clock skew for the time based claims.
+const DefaultClockSkew = time.Minute
+
+// Claims are the token claims.
+type Claims map[string]interface{}
+
+// Subject returns the "sub" claim.
+func (c Claims) Subject() string {
+	return c.str("sub")
+}
+
+// Issuer returns the "iss" claim.
+func (c Claims) Issuer() string {
+	return c.str("iss")
+}
+
+// Audience returns the "aud" claim which can be either a string or a list of strings.
+func (c Claims) Audience() []string {
+	return c.list("aud")
+}
+
+// Scopes returns the space separated "scope" claim or the "scp" list.
+func (c Claims) Scopes() []string {
+	if scope := c.str("scope"); scope != "" {
+		return strings.Fields(scope)
+	}
+
+	return c.list("scp")
+}
+
+// Roles returns the "roles" claim.
+func (c Claims) Roles() []string {
+	return c.list("roles")
+}
+
+// Time returns the numeric date claim, e.g. "exp".
+func (c Claims) Time(name string) (time.Time, bool) {
+	v, ok := c[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	sec := int64(v)
+	return time.Unix(sec, int64((v-float64(sec))*1e9)), true
+}
+
+func (c Claims) str(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+func (c Claims) list(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+
+	case []interface{}:
+		res := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				res = append(res, s)
+			}
+		}
+
+		return res
+	}
+
+	return nil
+}
+
+// Config is the token verifier configuration.
+type Config struct {
+	// Keys provides the verification keys.
+	Keys KeySource
+
+	// Algorithms is the list of allowed signing algorithms. Zero value means all the supported
+	// algorithms.
+	Algorithms []string
+
+	// Issuer is the expected "iss" claim. Empty value disables the check.
+	Issuer string
+
+	// Audience is the expected "aud" claim value. Empty value disables the check.
+	Audience string
+
+	// ClockSkew is the allowed clock skew for "exp", "nbf" and "iat" claims. Zero value means
+	// DefaultClockSkew.
+	ClockSkew time.Duration
+
+	// Cookie is the name of the cookie carrying the token if there is no Authorization header.
+	// Empty value disables the cookie.
+	Cookie string
+
+	// Realm is the realm reported in the WWW-Authenticate header.
+	Realm string
+
+	// Now returns the current time. Zero value means time.Now.
+	Now func() time.Time
+}
+
+// Verifier verifies the bearer tokens. It implements auth.Authenticator so it can be combined
+// with other authenticators with auth.New.
+type Verifier struct {
+	cfg        Config
+	algorithms map[string]bool
+}
+
+// NewVerifier creates a new token verifier.
+// The function panics if no key source is configured.
+func NewVerifier(cfg Config) *Verifier {
+	if cfg.Keys == nil {
+		panic("jwt: no key source")
+	}
+
+	if cfg.ClockSkew == 0 {
+		cfg.ClockSkew = DefaultClockSkew
+	}
+
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	if len(cfg.Algorithms) == 0 {
+		cfg.Algorithms = []string{HS256, HS384, HS512, RS256, ES256}
+	}
+
+	v := &Verifier{cfg: cfg, algorithms: make(map[string]bool)}
+	for _, alg := range cfg.Algorithms {
+		v.algorithms[alg] = true
+	}
+
+	return v
+}
+
+// New creates a middleware that verifies the bearer token and stores the claims in the request
+// context. The requests without a valid token are responded with HTTP 401.
+func New(cfg Config) middleware.Middleware {
+	return auth.New(NewVerifier(cfg))
+}
+
+// Verify verifies the token signature and claims.
+func (v *Verifier) Verify(token string) (Claims, error) {
+	hdr, claims, sig, input, err := parse(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if !v.algorithms[hdr.Alg] {
+		return nil, ErrAlgorithm
+	}
+
+	err = ErrNoKey
+	for _, key := range v.cfg.Keys.Lookup(hdr.Kid) {
+		if key.Algorithm != "" && key.Algorithm != hdr.Alg {
+			continue
+		}
+
+		if err = verifySignature(hdr.Alg, key.Key, input, sig); err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if claims == nil {
+		return nil, ErrMalformed
+	}
+
+	if err := v.validate(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func (v *Verifier) validate(claims Claims) error {
+	now := v.cfg.Now()
+	skew := v.cfg.ClockSkew
+
+	if exp, ok := claims.Time("exp"); ok && !now.Before(exp.Add(skew)) {
+		return ErrExpired
+	}
+
+	if nbf, ok := claims.Time("nbf"); ok && now.Add(skew).Before(nbf) {
+		return ErrNotValidYet
+	}
+
+	if iat, ok := claims.Time("iat"); ok && now.Add(skew).Before(iat) {
+		return ErrIssuedInFuture
+	}
+
+	if v.cfg.Issuer != "" && claims.Issuer() != v.cfg.Issuer {
+		return ErrIssuer
+	}
+
+	if v.cfg.Audience != "" {
+		found := false
+		for _, aud := range claims.Audience() {
+			if aud == v.cfg.Audience {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return ErrAudience
+		}
+	}
+
+	return nil
+}
+
+// Authenticate implements the auth.Authenticator interface.
+func (v *Verifier) Authenticate(r *http.Request) (*auth.Identity, error) {
+	token := v.token(r)
+	if token == "" {
+		return nil, auth.ErrNoCredentials
+	}
+
+	claims, err := v.Verify(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", auth.ErrInvalidCredentials, err)
+	}
+
+	return &auth.Identity{
+		Subject: claims.Subject(),
+		Method:  "jwt",
+		Roles:   claims.Roles(),
+		Scopes:  claims.Scopes(),
+		Claims:  claims,
+	}, nil
+}
+
+// Challenge implements the auth.Authenticator interface.
+func (v *Verifier) Challenge() string {
+	return fmt.Sprintf("Bearer realm=%q", v.cfg.Realm)
+}
+
+// token extracts the token from the Authorization header or the cookie.
+func (v *Verifier) token(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+
+	if v.cfg.Cookie != "" {
+		if c, err := r.Cookie(v.cfg.Cookie); err == nil {
+			return c.Value
+		}
+	}
+
+	return ""
+}
+
+// FromContext gets the verified token claims from the request context.
+func FromContext(ctx context.Context) (Claims, bool) {
+	id, ok := auth.Principal(ctx)
+	if !ok || id.Method != "jwt" {
+		return nil, false
+	}
+
+	return Claims(id.Claims), id.Claims != nil
+}
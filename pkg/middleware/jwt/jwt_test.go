@@ -0,0 +1,275 @@
+package jwt_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/middleware/auth"
+	"github.com/snobb/susanin/pkg/middleware/jwt"
+)
+
+var (
+	secret    = []byte("s3cr3t")
+	rsaKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _  = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	now       = time.Unix(1600000000, 0)
+)
+
+func sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	hdr := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		hdr["kid"] = kid
+	}
+
+	input := encode(t, hdr) + "." + encode(t, claims)
+
+	var sig []byte
+
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+
+	case "HS512":
+		mac := hmac.New(sha512.New, secret)
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+
+	case "RS256":
+		sum := sha256.Sum256([]byte(input))
+		var err error
+		sig, err = rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, sum[:])
+		assert.NoError(t, err)
+
+	case "ES256":
+		sum := sha256.Sum256([]byte(input))
+		r, s, err := ecdsa.Sign(rand.Reader, ecKey, sum[:])
+		assert.NoError(t, err)
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func encode(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	assert.NoError(t, err)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	valid := func(extra map[string]interface{}) map[string]interface{} {
+		claims := map[string]interface{}{
+			"sub": "john",
+			"iss": "https://issuer",
+			"aud": []string{"api", "web"},
+			"exp": now.Add(time.Hour).Unix(),
+			"nbf": now.Unix(),
+			"iat": now.Unix(),
+		}
+
+		for k, v := range extra {
+			claims[k] = v
+		}
+
+		return claims
+	}
+
+	tests := map[string]struct {
+		token   func(t *testing.T) string
+		wantErr error
+	}{
+		"should verify HS256 token": {
+			token: func(t *testing.T) string { return sign(t, "HS256", "hmac", valid(nil)) },
+		},
+		"should verify HS512 token": {
+			token: func(t *testing.T) string { return sign(t, "HS512", "hmac", valid(nil)) },
+		},
+		"should verify RS256 token": {
+			token: func(t *testing.T) string { return sign(t, "RS256", "rsa", valid(nil)) },
+		},
+		"should verify ES256 token": {
+			token: func(t *testing.T) string { return sign(t, "ES256", "ec", valid(nil)) },
+		},
+		"should verify token without kid": {
+			token: func(t *testing.T) string { return sign(t, "ES256", "", valid(nil)) },
+		},
+		"should reject token signed with a key of a different kid": {
+			token:   func(t *testing.T) string { return sign(t, "RS256", "ec", valid(nil)) },
+			wantErr: jwt.ErrNoKey,
+		},
+		"should reject tampered token": {
+			token: func(t *testing.T) string {
+				parts := strings.Split(sign(t, "HS256", "hmac", valid(nil)), ".")
+				parts[1] = encode(t, valid(map[string]interface{}{"sub": "admin"}))
+				return strings.Join(parts, ".")
+			},
+			wantErr: jwt.ErrSignature,
+		},
+		"should reject none algorithm": {
+			token: func(t *testing.T) string {
+				return encode(t, map[string]string{"alg": "none"}) + "." + encode(t, valid(nil)) + "."
+			},
+			wantErr: jwt.ErrAlgorithm,
+		},
+		"should reject malformed token": {
+			token:   func(t *testing.T) string { return "not.a-token" },
+			wantErr: jwt.ErrMalformed,
+		},
+		"should reject expired token": {
+			token: func(t *testing.T) string {
+				return sign(t, "HS256", "hmac", valid(map[string]interface{}{
+					"exp": now.Add(-2 * time.Minute).Unix(),
+				}))
+			},
+			wantErr: jwt.ErrExpired,
+		},
+		"should accept recently expired token within the clock skew": {
+			token: func(t *testing.T) string {
+				return sign(t, "HS256", "hmac", valid(map[string]interface{}{
+					"exp": now.Add(-30 * time.Second).Unix(),
+				}))
+			},
+		},
+		"should reject token used before nbf": {
+			token: func(t *testing.T) string {
+				return sign(t, "HS256", "hmac", valid(map[string]interface{}{
+					"nbf": now.Add(2 * time.Minute).Unix(),
+				}))
+			},
+			wantErr: jwt.ErrNotValidYet,
+		},
+		"should reject token issued in future": {
+			token: func(t *testing.T) string {
+				return sign(t, "HS256", "hmac", valid(map[string]interface{}{
+					"iat": now.Add(2 * time.Minute).Unix(),
+				}))
+			},
+			wantErr: jwt.ErrIssuedInFuture,
+		},
+		"should reject invalid issuer": {
+			token: func(t *testing.T) string {
+				return sign(t, "HS256", "hmac", valid(map[string]interface{}{"iss": "evil"}))
+			},
+			wantErr: jwt.ErrIssuer,
+		},
+		"should reject invalid audience": {
+			token: func(t *testing.T) string {
+				return sign(t, "HS256", "hmac", valid(map[string]interface{}{"aud": "other"}))
+			},
+			wantErr: jwt.ErrAudience,
+		},
+	}
+
+	v := jwt.NewVerifier(jwt.Config{
+		Keys: jwt.NewKeySet(
+			jwt.Key{ID: "hmac", Key: secret},
+			jwt.Key{ID: "rsa", Algorithm: jwt.RS256, Key: &rsaKey.PublicKey},
+			jwt.Key{ID: "ec", Algorithm: jwt.ES256, Key: &ecKey.PublicKey},
+		),
+		Issuer:   "https://issuer",
+		Audience: "api",
+		Now:      func() time.Time { return now },
+	})
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			claims, err := v.Verify(tt.token(t))
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), "got error %v, want %v", err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, "john", claims.Subject())
+		})
+	}
+}
+
+func TestJWT_New(t *testing.T) {
+	cfg := jwt.Config{
+		Keys:   jwt.NewKeySet(jwt.Key{Key: secret}),
+		Cookie: "token",
+		Realm:  "api",
+		Now:    func() time.Time { return now },
+	}
+
+	token := sign(t, "HS256", "", map[string]interface{}{
+		"sub":   "john",
+		"scope": "users:read users:write",
+		"roles": []string{"admin"},
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+
+	tests := map[string]struct {
+		setup    func(r *http.Request)
+		wantCode int
+	}{
+		"should authenticate with bearer token": {
+			setup:    func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) },
+			wantCode: 200,
+		},
+		"should authenticate with cookie": {
+			setup:    func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "token", Value: token}) },
+			wantCode: 200,
+		},
+		"should reject missing token": {
+			setup:    func(r *http.Request) {},
+			wantCode: 401,
+		},
+		"should reject invalid token": {
+			setup:    func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token+"x") },
+			wantCode: 401,
+		},
+	}
+
+	h := jwt.New(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := jwt.FromContext(r.Context())
+		assert.True(t, ok)
+		assert.Equal(t, "john", claims.Subject())
+
+		id, ok := auth.Principal(r.Context())
+		assert.True(t, ok)
+		assert.Equal(t, &auth.Identity{
+			Subject: "john",
+			Method:  "jwt",
+			Roles:   []string{"admin"},
+			Scopes:  []string{"users:read", "users:write"},
+			Claims:  id.Claims,
+		}, id)
+	}))
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			tt.setup(req)
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode == 401 {
+				assert.Equal(t, `Bearer realm="api"`, rec.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
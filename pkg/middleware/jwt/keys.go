@@ -0,0 +1,260 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+)
+
+// Key is a token verification key.
+type Key struct {
+	// ID is matched against the token "kid" header. Keys with an empty ID match any token.
+	ID string
+
+	// Algorithm restricts the key to the algorithm. Empty value means any algorithm compatible
+	// with the key type.
+	Algorithm string
+
+	// Key is a []byte secret for HMAC, *rsa.PublicKey for RS256 or *ecdsa.PublicKey for ES256.
+	Key interface{}
+}
+
+// KeySource provides the verification keys.
+type KeySource interface {
+	// Lookup returns the candidate keys for the key ID. An empty key ID matches all the keys.
+	Lookup(kid string) []Key
+}
+
+// KeySet is a static KeySource.
+type KeySet struct {
+	keys []Key
+}
+
+// NewKeySet creates a static key set.
+func NewKeySet(keys ...Key) *KeySet {
+	return &KeySet{keys: keys}
+}
+
+// Lookup implements the KeySource interface.
+func (ks *KeySet) Lookup(kid string) []Key {
+	if kid == "" {
+		return ks.keys
+	}
+
+	var keys []Key
+	for _, key := range ks.keys {
+		if key.ID == kid || key.ID == "" {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// errUnsupported is returned for the keys of unsupported types and curves.
+var errUnsupported = errors.New("unsupported")
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	K   string `json:"k"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJWKS parses a JSON Web Key Set document. Only the signature keys of types "oct", "RSA"
+// and "EC" (P-256) are loaded, the keys of other types and curves, e.g. "OKP" or P-384, are
+// skipped. The function fails if there are no keys left.
+func ParseJWKS(data []byte) (*KeySet, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+
+	ks := &KeySet{}
+
+	for i, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.key()
+		if errors.Is(err, errUnsupported) {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("jwks: key %d (%s): %w", i, k.Kid, err)
+		}
+
+		ks.keys = append(ks.keys, Key{ID: k.Kid, Algorithm: k.Alg, Key: key})
+	}
+
+	if len(ks.keys) == 0 {
+		return nil, fmt.Errorf("jwks: no supported signature keys")
+	}
+
+	return ks, nil
+}
+
+func (k *jwk) key() (interface{}, error) {
+	switch k.Kty {
+	case "oct":
+		return decodeSegment(k.K)
+
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("%w curve: %s", errUnsupported, k.Crv)
+		}
+
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, fmt.Errorf("invalid EC point")
+		}
+
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+
+	default:
+		return nil, fmt.Errorf("%w key type: %s", errUnsupported, k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	data, err := decodeSegment(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty key parameter")
+	}
+
+	return new(big.Int).SetBytes(data), nil
+}
+
+// JWKSFile is a KeySource backed by a local JWKS file that is periodically reloaded.
+type JWKSFile struct {
+	path    string
+	mu      sync.RWMutex
+	keys    *KeySet
+	modTime time.Time
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewJWKSFile loads the JWKS file and reloads it every interval if it has been modified.
+// Zero interval disables the periodic reload. Failed reloads keep the current keys.
+func NewJWKSFile(path string, interval time.Duration) (*JWKSFile, error) {
+	jf := &JWKSFile{path: path, done: make(chan struct{})}
+	if err := jf.Reload(); err != nil {
+		return nil, err
+	}
+
+	if interval > 0 {
+		go jf.watch(interval)
+	}
+
+	return jf, nil
+}
+
+// Lookup implements the KeySource interface.
+func (jf *JWKSFile) Lookup(kid string) []Key {
+	jf.mu.RLock()
+	defer jf.mu.RUnlock()
+
+	return jf.keys.Lookup(kid)
+}
+
+// Reload reloads the file if it has been modified since the last load.
+func (jf *JWKSFile) Reload() error {
+	info, err := os.Stat(jf.path)
+	if err != nil {
+		return err
+	}
+
+	jf.mu.RLock()
+	unchanged := jf.keys != nil && info.ModTime().Equal(jf.modTime)
+	jf.mu.RUnlock()
+
+	if unchanged {
+		return nil
+	}
+
+	data, err := os.ReadFile(jf.path)
+	if err != nil {
+		return err
+	}
+
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return err
+	}
+
+	jf.mu.Lock()
+	jf.keys, jf.modTime = keys, info.ModTime()
+	jf.mu.Unlock()
+
+	return nil
+}
+
+// Close stops the periodic reload.
+func (jf *JWKSFile) Close() {
+	jf.once.Do(func() {
+		close(jf.done)
+	})
+}
+
+func (jf *JWKSFile) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = jf.Reload()
+		case <-jf.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package jwt_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/middleware/jwt"
+)
+
+func jwks(t *testing.T, keys ...map[string]string) []byte {
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	assert.NoError(t, err)
+	return data
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func TestParseJWKS(t *testing.T) {
+	tests := map[string]struct {
+		keys     []map[string]string
+		wantKeys int
+		wantErr  bool
+	}{
+		"should parse oct, RSA and EC keys": {
+			keys: []map[string]string{
+				{"kty": "oct", "kid": "hmac", "k": b64(secret)},
+				{
+					"kty": "RSA", "kid": "rsa", "alg": "RS256",
+					"n": b64(rsaKey.N.Bytes()),
+					"e": b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+				},
+				{
+					"kty": "EC", "kid": "ec", "crv": "P-256",
+					"x": b64(ecKey.X.Bytes()),
+					"y": b64(ecKey.Y.Bytes()),
+				},
+			},
+			wantKeys: 3,
+		},
+		"should skip encryption keys": {
+			keys: []map[string]string{
+				{"kty": "oct", "kid": "enc", "use": "enc", "k": b64(secret)},
+				{"kty": "oct", "kid": "sig", "use": "sig", "k": b64(secret)},
+			},
+			wantKeys: 1,
+		},
+		"should skip unsupported key types and curves": {
+			keys: []map[string]string{
+				{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": "AA"},
+				{"kty": "EC", "kid": "p384", "crv": "P-384", "x": "AA", "y": "AA"},
+				{"kty": "oct", "kid": "hmac", "k": b64(secret)},
+			},
+			wantKeys: 1,
+		},
+		"should reject set without supported keys": {
+			keys: []map[string]string{
+				{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": "AA"},
+				{"kty": "EC", "crv": "P-384", "x": "AA", "y": "AA"},
+			},
+			wantErr: true,
+		},
+		"should reject set with encryption keys only": {
+			keys: []map[string]string{
+				{"kty": "oct", "kid": "enc", "use": "enc", "k": b64(secret)},
+			},
+			wantErr: true,
+		},
+		"should reject invalid EC points": {
+			keys:    []map[string]string{{"kty": "EC", "crv": "P-256", "x": "AQ", "y": "AQ"}},
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ks, err := jwt.ParseJWKS(jwks(t, tt.keys...))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Len(t, ks.Lookup(""), tt.wantKeys)
+		})
+	}
+}
+
+func TestJWKSFile_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	write := func(kid string, mtime time.Time) {
+		data := jwks(t, map[string]string{"kty": "oct", "kid": kid, "k": b64(secret)})
+		assert.NoError(t, os.WriteFile(path, data, 0o600))
+		assert.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+
+	write("v1", now)
+
+	jf, err := jwt.NewJWKSFile(path, 5*time.Millisecond)
+	assert.NoError(t, err)
+	defer jf.Close()
+
+	v := jwt.NewVerifier(jwt.Config{Keys: jf, Now: func() time.Time { return now }})
+
+	_, err = v.Verify(sign(t, "HS256", "v1", map[string]interface{}{"sub": "john"}))
+	assert.NoError(t, err)
+
+	write("v2", now.Add(time.Second))
+
+	assert.Eventually(t, func() bool {
+		return len(jf.Lookup("v2")) == 1 && len(jf.Lookup("v1")) == 0
+	}, time.Second, 5*time.Millisecond)
+
+	_, err = v.Verify(sign(t, "HS256", "v2", map[string]interface{}{"sub": "john"}))
+	assert.NoError(t, err)
+
+	// invalid files keep the current keys
+	assert.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+	assert.NoError(t, os.Chtimes(path, now.Add(time.Minute), now.Add(time.Minute)))
+	assert.Error(t, jf.Reload())
+	assert.Len(t, jf.Lookup("v2"), 1)
+}
@@ -0,0 +1,152 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"strings"
+)
+
+// Supported signing algorithms
+const (
+	HS256 = "HS256"
+	HS384 = "HS384"
+	HS512 = "HS512"
+	RS256 = "RS256"
+	ES256 = "ES256"
+)
+
+var (
+	// ErrMalformed is returned if the token cannot be parsed.
+	ErrMalformed = errors.New("malformed token")
+
+	// ErrAlgorithm is returned if the token algorithm is not allowed.
+	ErrAlgorithm = errors.New("unsupported signing algorithm")
+
+	// ErrNoKey is returned if there is no key to verify the token.
+	ErrNoKey = errors.New("no matching key")
+
+	// ErrSignature is returned if the token signature is invalid.
+	ErrSignature = errors.New("invalid signature")
+)
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+// parse splits the token and decodes its header, claims and signature.
+func parse(token string) (*header, Claims, []byte, string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, nil, "", ErrMalformed
+	}
+
+	var hdr header
+	if err := decodeJSON(parts[0], &hdr); err != nil {
+		return nil, nil, nil, "", ErrMalformed
+	}
+
+	var claims Claims
+	if err := decodeJSON(parts[1], &claims); err != nil {
+		return nil, nil, nil, "", ErrMalformed
+	}
+
+	sig, err := decodeSegment(parts[2])
+	if err != nil {
+		return nil, nil, nil, "", ErrMalformed
+	}
+
+	return &hdr, claims, sig, parts[0] + "." + parts[1], nil
+}
+
+// verifySignature verifies the signature of the signing input with the key.
+func verifySignature(alg string, key interface{}, input string, sig []byte) error {
+	switch alg {
+	case HS256, HS384, HS512:
+		secret, ok := key.([]byte)
+		if !ok {
+			return ErrNoKey
+		}
+
+		mac := hmac.New(hashFunc(alg), secret)
+		mac.Write([]byte(input))
+
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return ErrSignature
+		}
+
+	case RS256:
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrNoKey
+		}
+
+		sum := sha256.Sum256([]byte(input))
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig) != nil {
+			return ErrSignature
+		}
+
+	case ES256:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || pub.Curve != elliptic.P256() {
+			return ErrNoKey
+		}
+
+		if len(sig) != 64 {
+			return ErrSignature
+		}
+
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		sum := sha256.Sum256([]byte(input))
+
+		if !ecdsa.Verify(pub, sum[:], r, s) {
+			return ErrSignature
+		}
+
+	default:
+		return ErrAlgorithm
+	}
+
+	return nil
+}
+
+func hashFunc(alg string) func() hash.Hash {
+	switch alg {
+	case HS384:
+		return sha512.New384
+	case HS512:
+		return sha512.New
+	default:
+		return sha256.New
+	}
+}
+
+func decodeSegment(seg string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(seg, "="))
+}
+
+func decodeJSON(seg string, v interface{}) error {
+	data, err := decodeSegment(seg)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	return nil
+}
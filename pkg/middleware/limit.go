@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+// LimitBody limits the request body to n bytes with http.MaxBytesReader. Once reading the body
+// fails with *http.MaxBytesError, the handler response is replaced with the one written by
+// reject unless the handler has already started responding. The handler must be served with the
+// returned writer and body.
+func LimitBody(w http.ResponseWriter, body io.ReadCloser, n int64,
+	reject func(w http.ResponseWriter)) (http.ResponseWriter, io.ReadCloser) {
+	lw := &limitWriter{ResponseWriter: w, reject: reject}
+	return lw, &limitReader{ReadCloser: http.MaxBytesReader(w, body, n), lw: lw}
+}
+
+// limitReader records if the body exceeded the limit.
+type limitReader struct {
+	io.ReadCloser
+	lw *limitWriter
+}
+
+func (lr *limitReader) Read(p []byte) (int, error) {
+	n, err := lr.ReadCloser.Read(p)
+
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		lr.lw.exceeded = true
+	}
+
+	return n, err
+}
+
+// limitWriter writes the reject response instead of the handler one once the body limit is
+// exceeded.
+type limitWriter struct {
+	http.ResponseWriter
+	reject   func(w http.ResponseWriter)
+	exceeded bool
+	written  bool
+	rejected bool
+}
+
+// WriteHeader implements the http.ResponseWriter interface.
+func (lw *limitWriter) WriteHeader(status int) {
+	if lw.written {
+		return
+	}
+
+	lw.written = true
+
+	if lw.exceeded {
+		lw.rejected = true
+		lw.reject(lw.ResponseWriter)
+		return
+	}
+
+	lw.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements the http.ResponseWriter interface.
+func (lw *limitWriter) Write(data []byte) (int, error) {
+	if !lw.written {
+		lw.WriteHeader(http.StatusOK)
+	}
+
+	if lw.rejected {
+		return len(data), nil
+	}
+
+	return lw.ResponseWriter.Write(data)
+}
+
+// Flush implements the http.Flusher interface.
+func (lw *limitWriter) Flush() {
+	if f, ok := lw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,322 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/snobb/susanin/pkg/framework"
+	"github.com/snobb/susanin/pkg/middleware"
+)
+
+// The forwarding headers.
+const (
+	Forwarded     = "Forwarded"
+	XForwardedFor = "X-Forwarded-For"
+	XRealIP       = "X-Real-IP"
+)
+
+// info is the resolved client information.
+type info struct {
+	ip     net.IP
+	scheme string
+	host   string
+}
+
+// Host implements the framework.Forwarded interface.
+func (inf *info) Host() string {
+	return inf.host
+}
+
+// load gets the resolved client information from the context.
+func load(ctx context.Context) (*info, bool) {
+	f, _ := framework.GetForwarded(ctx)
+	inf, ok := f.(*info)
+	return inf, ok
+}
+
+// Config is the proxy middleware configuration.
+type Config struct {
+	// TrustedProxies is a list of the CIDRs or the IP addresses of the trusted proxies. The
+	// forwarding headers are ignored unless the request comes from a trusted proxy.
+	TrustedProxies []string
+
+	// Header is the forwarding header maintained by the trusted proxies: Forwarded,
+	// X-Forwarded-For along with X-Forwarded-Proto and X-Forwarded-Host, or X-Real-IP. The
+	// other forwarding headers are ignored as they might be sent by the client. Zero value
+	// means X-Forwarded-For.
+	Header string
+}
+
+type resolver struct {
+	trusted []*net.IPNet
+	header  string
+}
+
+// New creates a middleware resolving the client IP address, the scheme and the host of the
+// requests coming through the trusted proxies from the configured forwarding header. The
+// forwarded addresses are walked from right to left skipping the trusted proxies, so the
+// addresses prepended by the client cannot be spoofed. Only the hops appended by the trusted
+// proxies are used. The results are available with ClientIP, Scheme and Host.
+// The function panics if a trusted proxy is neither a valid CIDR nor an IP address or if the
+// header is not supported.
+func New(cfg Config) middleware.Middleware {
+	res := &resolver{header: http.CanonicalHeaderKey(cfg.Header)}
+
+	switch res.header {
+	case "":
+		res.header = XForwardedFor
+	case Forwarded, XForwardedFor, http.CanonicalHeaderKey(XRealIP):
+	default:
+		panic(fmt.Sprintf("proxy: unsupported header: %q", cfg.Header))
+	}
+
+	for _, proxy := range cfg.TrustedProxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil {
+				bits := 8 * len(ip.To16())
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 32
+				}
+
+				proxy = fmt.Sprintf("%s/%d", ip, bits)
+			}
+		}
+
+		_, cidr, err := net.ParseCIDR(proxy)
+		if err != nil {
+			panic(fmt.Sprintf("proxy: invalid trusted proxy: %q", proxy))
+		}
+
+		res.trusted = append(res.trusted, cidr)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			inf := res.resolve(r)
+			next.ServeHTTP(w, r.WithContext(framework.WithForwarded(r.Context(), inf)))
+		})
+	}
+}
+
+// ClientIP returns the client IP address resolved by the middleware.
+func ClientIP(ctx context.Context) (net.IP, bool) {
+	inf, ok := load(ctx)
+	if !ok || inf.ip == nil {
+		return nil, false
+	}
+
+	return inf.ip, true
+}
+
+// Scheme returns the scheme, "http" or "https", used by the client.
+func Scheme(ctx context.Context) (string, bool) {
+	inf, ok := load(ctx)
+	if !ok {
+		return "", false
+	}
+
+	return inf.scheme, true
+}
+
+// Host returns the host requested by the client.
+func Host(ctx context.Context) (string, bool) {
+	inf, ok := load(ctx)
+	if !ok {
+		return "", false
+	}
+
+	return inf.host, true
+}
+
+// hop is a forwarding hop.
+type hop struct {
+	addr   string
+	scheme string
+	host   string
+}
+
+func (res *resolver) resolve(r *http.Request) *info {
+	inf := &info{ip: parseIP(r.RemoteAddr), scheme: "http", host: r.Host}
+	if r.TLS != nil {
+		inf.scheme = "https"
+	}
+
+	if !res.isTrusted(inf.ip) {
+		return inf
+	}
+
+	var hops []hop
+
+	switch res.header {
+	case Forwarded:
+		hops = forwarded(r.Header)
+	case XForwardedFor:
+		hops = xForwarded(r.Header)
+	default:
+		// the header is set by the nearest proxy, so it holds a single address
+		if values := r.Header.Values(XRealIP); len(values) != 0 {
+			hops = []hop{{addr: values[len(values)-1]}}
+		}
+	}
+
+	// walk from right to left until the first address that is not a trusted proxy. Each hop is
+	// appended by the proxy at the address of the hop on its right, the rightmost one by the
+	// remote peer, so the hop passes the trust check if that proxy is trusted.
+	for i, trusted := len(hops)-1, true; i >= 0 && trusted; i-- {
+		ip := parseIP(hops[i].addr)
+		if ip == nil {
+			break
+		}
+
+		inf.ip = ip
+
+		if scheme := strings.ToLower(hops[i].scheme); scheme == "http" || scheme == "https" {
+			inf.scheme = scheme
+		}
+
+		if hops[i].host != "" {
+			inf.host = hops[i].host
+		}
+
+		trusted = res.isTrusted(ip)
+	}
+
+	return inf
+}
+
+func (res *resolver) isTrusted(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, cidr := range res.trusted {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// forwarded parses the RFC 7239 Forwarded header, e.g.
+// Forwarded: for=192.0.2.60;proto=http;host=example.com, for="[2001:db8::1]:4711".
+func forwarded(h http.Header) []hop {
+	var hops []hop
+
+	for _, line := range h.Values("Forwarded") {
+		for _, elem := range splitQuoted(line, ',') {
+			var hp hop
+
+			for _, pair := range splitQuoted(elem, ';') {
+				eq := strings.IndexByte(pair, '=')
+				if eq < 0 {
+					continue
+				}
+
+				value := strings.Trim(strings.TrimSpace(pair[eq+1:]), `"`)
+
+				switch strings.ToLower(strings.TrimSpace(pair[:eq])) {
+				case "for":
+					hp.addr = value
+				case "proto":
+					hp.scheme = value
+				case "host":
+					hp.host = value
+				}
+			}
+
+			hops = append(hops, hp)
+		}
+	}
+
+	return hops
+}
+
+// xForwarded parses the X-Forwarded-For header. The X-Forwarded-Proto and X-Forwarded-Host
+// values are matched with the addresses by position if their numbers are equal, otherwise
+// they cannot be attributed to the hops appended by the trusted proxies and are ignored.
+func xForwarded(h http.Header) []hop {
+	addrs := list(h, "X-Forwarded-For")
+	if len(addrs) == 0 {
+		return nil
+	}
+
+	hops := make([]hop, len(addrs))
+	for i, addr := range addrs {
+		hops[i].addr = addr
+	}
+
+	assign := func(values []string, set func(hp *hop, value string)) {
+		if len(values) == len(hops) {
+			for i, value := range values {
+				set(&hops[i], value)
+			}
+		}
+	}
+
+	assign(list(h, "X-Forwarded-Proto"), func(hp *hop, value string) { hp.scheme = value })
+	assign(list(h, "X-Forwarded-Host"), func(hp *hop, value string) { hp.host = value })
+
+	return hops
+}
+
+// list returns the comma separated values of all the header lines.
+func list(h http.Header, name string) []string {
+	var res []string
+
+	for _, line := range h.Values(name) {
+		for _, value := range strings.Split(line, ",") {
+			res = append(res, strings.TrimSpace(value))
+		}
+	}
+
+	return res
+}
+
+// splitQuoted splits the string by the separator outside of the quoted strings.
+func splitQuoted(s string, sep byte) []string {
+	var (
+		res    []string
+		quoted bool
+		start  int
+	)
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case '\\':
+			if quoted {
+				i++
+			}
+		case sep:
+			if !quoted {
+				res = append(res, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(res, s[start:])
+}
+
+// parseIP parses the IP address optionally with a port, e.g. "[2001:db8::1]:4711".
+func parseIP(addr string) net.IP {
+	addr = strings.TrimSpace(addr)
+
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+
+	addr = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+
+	ip := net.ParseIP(addr)
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+
+	return ip
+}
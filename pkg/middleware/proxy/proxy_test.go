@@ -0,0 +1,235 @@
+package proxy_test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/middleware/proxy"
+)
+
+func TestProxy(t *testing.T) {
+	tests := map[string]struct {
+		remoteAddr string
+		tls        bool
+		trusted    string // header maintained by the trusted proxies
+		header     http.Header
+		wantIP     string
+		wantScheme string
+		wantHost   string
+	}{
+		"should use remote address without proxy headers": {
+			remoteAddr: "203.0.113.7:1234",
+			wantIP:     "203.0.113.7",
+			wantScheme: "http",
+			wantHost:   "example.com",
+		},
+		"should use TLS state for the scheme": {
+			remoteAddr: "203.0.113.7:1234",
+			tls:        true,
+			wantIP:     "203.0.113.7",
+			wantScheme: "https",
+			wantHost:   "example.com",
+		},
+		"should ignore headers from untrusted remote": {
+			remoteAddr: "203.0.113.7:1234",
+			header: http.Header{
+				"X-Forwarded-For":   {"198.51.100.1"},
+				"X-Forwarded-Proto": {"https"},
+				"X-Real-Ip":         {"198.51.100.2"},
+			},
+			wantIP:     "203.0.113.7",
+			wantScheme: "http",
+			wantHost:   "example.com",
+		},
+		"should resolve X-Forwarded headers from trusted proxy": {
+			remoteAddr: "10.0.0.1:1234",
+			header: http.Header{
+				"X-Forwarded-For":   {"198.51.100.1"},
+				"X-Forwarded-Proto": {"https"},
+				"X-Forwarded-Host":  {"api.example.com"},
+			},
+			wantIP:     "198.51.100.1",
+			wantScheme: "https",
+			wantHost:   "api.example.com",
+		},
+		"should skip trusted proxies right to left": {
+			remoteAddr: "10.0.0.1:1234",
+			header: http.Header{
+				"X-Forwarded-For": {"1.1.1.1, 198.51.100.1", "10.0.0.2"},
+			},
+			wantIP:     "198.51.100.1",
+			wantScheme: "http",
+			wantHost:   "example.com",
+		},
+		"should use the leftmost address if all proxies are trusted": {
+			remoteAddr: "10.0.0.1:1234",
+			header:     http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			wantIP:     "10.0.0.3",
+			wantScheme: "http",
+			wantHost:   "example.com",
+		},
+		"should stop at invalid address": {
+			remoteAddr: "10.0.0.1:1234",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.1, garbage, 10.0.0.2"}},
+			wantIP:     "10.0.0.2",
+			wantScheme: "http",
+			wantHost:   "example.com",
+		},
+		"should match protocols by position": {
+			remoteAddr: "10.0.0.1:1234",
+			header: http.Header{
+				"X-Forwarded-For":   {"198.51.100.1, 10.0.0.2"},
+				"X-Forwarded-Proto": {"https, http"},
+			},
+			wantIP:     "198.51.100.1",
+			wantScheme: "https",
+			wantHost:   "example.com",
+		},
+		"should ignore invalid scheme": {
+			remoteAddr: "10.0.0.1:1234",
+			header: http.Header{
+				"X-Forwarded-For":   {"198.51.100.1"},
+				"X-Forwarded-Proto": {"javascript"},
+			},
+			wantIP:     "198.51.100.1",
+			wantScheme: "http",
+			wantHost:   "example.com",
+		},
+		"should use Forwarded header": {
+			remoteAddr: "10.0.0.1:1234",
+			trusted:    proxy.Forwarded,
+			header: http.Header{
+				"Forwarded": {
+					`for="[2001:db8::1]:4711";proto=https;host="shop.example.com", ` +
+						`for=10.0.0.2;proto=http`,
+				},
+				"X-Forwarded-For": {"198.51.100.1"},
+			},
+			wantIP:     "2001:db8::1",
+			wantScheme: "https",
+			wantHost:   "shop.example.com",
+		},
+		"should not trust spoofed Forwarded elements": {
+			remoteAddr: "10.0.0.1:1234",
+			trusted:    proxy.Forwarded,
+			header: http.Header{
+				"Forwarded": {`for=1.2.3.4;host=evil.com, for=198.51.100.1;proto=https`},
+			},
+			wantIP:     "198.51.100.1",
+			wantScheme: "https",
+			wantHost:   "example.com",
+		},
+		"should use X-Real-IP": {
+			remoteAddr: "[::1]:1234",
+			trusted:    proxy.XRealIP,
+			header:     http.Header{"X-Real-Ip": {"198.51.100.1"}},
+			wantIP:     "198.51.100.1",
+			wantScheme: "http",
+			wantHost:   "example.com",
+		},
+		"should ignore Forwarded header sent by the client": {
+			remoteAddr: "10.0.0.1:1234",
+			header: http.Header{
+				"Forwarded":       {`for=1.2.3.4;proto=https;host=evil.com`},
+				"X-Forwarded-For": {"203.0.113.9"},
+			},
+			wantIP:     "203.0.113.9",
+			wantScheme: "http",
+			wantHost:   "example.com",
+		},
+		"should ignore Forwarded header without X-Forwarded-For": {
+			remoteAddr: "10.0.0.1:1234",
+			header:     http.Header{"Forwarded": {`for=1.2.3.4`}},
+			wantIP:     "10.0.0.1",
+			wantScheme: "http",
+			wantHost:   "example.com",
+		},
+		"should ignore X-Real-IP sent by the client": {
+			remoteAddr: "10.0.0.1:1234",
+			header: http.Header{
+				"X-Forwarded-For": {"203.0.113.9"},
+				"X-Real-Ip":       {"1.2.3.4"},
+			},
+			wantIP:     "203.0.113.9",
+			wantScheme: "http",
+			wantHost:   "example.com",
+		},
+		"should ignore X-Forwarded-For with X-Real-IP maintained": {
+			remoteAddr: "10.0.0.1:1234",
+			trusted:    proxy.XRealIP,
+			header: http.Header{
+				"X-Forwarded-For": {"1.2.3.4"},
+				"X-Real-Ip":       {"203.0.113.9"},
+			},
+			wantIP:     "203.0.113.9",
+			wantScheme: "http",
+			wantHost:   "example.com",
+		},
+		"should ignore X-Forwarded-Host not matching the hops": {
+			remoteAddr: "10.0.0.1:1234",
+			header: http.Header{
+				"X-Forwarded-For":   {"1.2.3.4, 203.0.113.9"},
+				"X-Forwarded-Proto": {"https"},
+				"X-Forwarded-Host":  {"evil.com"},
+			},
+			wantIP:     "203.0.113.9",
+			wantScheme: "http",
+			wantHost:   "example.com",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mw := proxy.New(proxy.Config{
+				TrustedProxies: []string{"10.0.0.0/8", "::1"},
+				Header:         tt.trusted,
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+
+			var called bool
+			mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+
+				ip, ok := proxy.ClientIP(r.Context())
+				assert.True(t, ok)
+				assert.Equal(t, tt.wantIP, ip.String())
+
+				scheme, ok := proxy.Scheme(r.Context())
+				assert.True(t, ok)
+				assert.Equal(t, tt.wantScheme, scheme)
+
+				host, ok := proxy.Host(r.Context())
+				assert.True(t, ok)
+				assert.Equal(t, tt.wantHost, host)
+			})).ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.True(t, called)
+		})
+	}
+}
+
+func TestProxy_InvalidConfig(t *testing.T) {
+	assert.Panics(t, func() {
+		proxy.New(proxy.Config{TrustedProxies: []string{"10.0.0.0/33"}})
+	})
+
+	assert.Panics(t, func() {
+		proxy.New(proxy.Config{Header: "X-Client-IP"})
+	})
+
+	_, ok := proxy.ClientIP(httptest.NewRequest(http.MethodGet, "/", nil).Context())
+	assert.False(t, ok)
+}
@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/snobb/susanin/pkg/framework"
+	"github.com/snobb/susanin/pkg/middleware/proxy"
+)
+
+// KeyFunc extracts the rate limiting key from the request.
+type KeyFunc func(r *http.Request) string
+
+// ByIP keys the requests by the client IP address. The address resolved by the proxy
+// middleware is used if it is installed, otherwise the remote address of the connection.
+func ByIP(r *http.Request) string {
+	if ip, ok := proxy.ClientIP(r.Context()); ok {
+		return ip.String()
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// ByHeader keys the requests by the header value, e.g. an API key.
+func ByHeader(name string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// ByContext keys the requests by the request context value, e.g. an authenticated subject.
+// The value must be a string or a fmt.Stringer.
+func ByContext(key interface{}) KeyFunc {
+	return func(r *http.Request) string {
+		switch v := r.Context().Value(key).(type) {
+		case string:
+			return v
+		case fmt.Stringer:
+			return v.String()
+		default:
+			return ""
+		}
+	}
+}
+
+// ByRoute keys the requests by the method and the matched route pattern, so that all the
+// clients share the limit of the route. The pattern is known to the route middlewares only, so
+// the middleware must be attached with framework.Use. The key is empty, i.e. the requests are
+// not limited, if there is no pattern, e.g. with the middleware attached with Framework.Attach.
+func ByRoute(r *http.Request) string {
+	pattern, ok := framework.GetPattern(r.Context())
+	if !ok {
+		return ""
+	}
+
+	return r.Method + " " + pattern
+}
+
+// Compose combines several keys, e.g. the route and the client IP. If any of the keys is empty
+// the result is empty.
+func Compose(keys ...KeyFunc) KeyFunc {
+	return func(r *http.Request) string {
+		parts := make([]string, 0, len(keys))
+
+		for _, key := range keys {
+			part := key(r)
+			if part == "" {
+				return ""
+			}
+
+			parts = append(parts, part)
+		}
+
+		return strings.Join(parts, "|")
+	}
+}
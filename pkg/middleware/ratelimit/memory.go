@@ -0,0 +1,171 @@
+package ratelimit
+
+import (
+	"container/list"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultShards is the default number of the memory store shards.
+	DefaultShards = 32
+
+	// DefaultMaxKeys is the default maximum number of keys kept by the memory store.
+	DefaultMaxKeys = 100000
+
+	// DefaultSweepInterval is the default interval between sweeps of the idle buckets.
+	DefaultSweepInterval = time.Minute
+)
+
+// MemoryConfig is the in-memory store configuration.
+type MemoryConfig struct {
+	// Shards is the number of independently locked shards. Zero value means DefaultShards.
+	Shards int
+
+	// MaxKeys is the maximum number of buckets kept in the store. When the limit is reached
+	// the least recently used bucket of the shard is evicted. Zero value means DefaultMaxKeys.
+	MaxKeys int
+
+	// SweepInterval is the minimal interval between the sweeps of the buckets that have been
+	// refilled completely and can be forgotten. Zero value means DefaultSweepInterval.
+	SweepInterval time.Duration
+}
+
+// MemoryStore is a sharded in-memory Store implementation.
+type MemoryStore struct {
+	shards        []*shard
+	maxKeys       int
+	sweepInterval time.Duration
+}
+
+type shard struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lru       *list.List // buckets ordered from the most recently used one
+	lastSweep time.Time
+}
+
+type bucket struct {
+	key    string
+	elem   *list.Element
+	tokens float64
+	last   time.Time
+	full   time.Time // time the bucket is refilled completely
+}
+
+// NewMemoryStore creates a new in-memory store.
+func NewMemoryStore(cfg MemoryConfig) *MemoryStore {
+	if cfg.Shards <= 0 {
+		cfg.Shards = DefaultShards
+	}
+
+	if cfg.MaxKeys <= 0 {
+		cfg.MaxKeys = DefaultMaxKeys
+	}
+
+	if cfg.SweepInterval <= 0 {
+		cfg.SweepInterval = DefaultSweepInterval
+	}
+
+	ms := &MemoryStore{
+		shards:        make([]*shard, cfg.Shards),
+		maxKeys:       (cfg.MaxKeys + cfg.Shards - 1) / cfg.Shards,
+		sweepInterval: cfg.SweepInterval,
+	}
+
+	for i := range ms.shards {
+		ms.shards[i] = &shard{buckets: make(map[string]*bucket), lru: list.New()}
+	}
+
+	return ms
+}
+
+// Take implements the Store interface.
+func (ms *MemoryStore) Take(key string, rate Rate, now time.Time) (Result, error) {
+	sh := ms.shard(key)
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	if now.Sub(sh.lastSweep) >= ms.sweepInterval {
+		sh.sweep(now)
+	}
+
+	limit := float64(rate.Limit)
+	perToken := rate.Period / time.Duration(rate.Limit)
+
+	b, ok := sh.buckets[key]
+	if !ok {
+		if len(sh.buckets) >= ms.maxKeys {
+			sh.evict()
+		}
+
+		b = &bucket{key: key, tokens: limit, last: now}
+		b.elem = sh.lru.PushFront(b)
+		sh.buckets[key] = b
+	} else {
+		sh.lru.MoveToFront(b.elem)
+
+		if elapsed := now.Sub(b.last); elapsed > 0 {
+			b.tokens = math.Min(limit, b.tokens+elapsed.Seconds()/perToken.Seconds())
+			b.last = now
+		}
+	}
+
+	res := Result{Limit: rate.Limit}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - b.tokens) * float64(perToken))
+	}
+
+	res.Remaining = int(b.tokens)
+	res.Reset = time.Duration((limit - b.tokens) * float64(perToken))
+	b.full = now.Add(res.Reset)
+
+	return res, nil
+}
+
+// Len returns the number of buckets kept in the store.
+func (ms *MemoryStore) Len() int {
+	n := 0
+
+	for _, sh := range ms.shards {
+		sh.mu.Lock()
+		n += len(sh.buckets)
+		sh.mu.Unlock()
+	}
+
+	return n
+}
+
+func (ms *MemoryStore) shard(key string) *shard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return ms.shards[h.Sum32()%uint32(len(ms.shards))]
+}
+
+// sweep removes the buckets that have been refilled completely as they are equivalent to the
+// new ones.
+func (sh *shard) sweep(now time.Time) {
+	sh.lastSweep = now
+
+	for key, b := range sh.buckets {
+		if !now.Before(b.full) {
+			sh.lru.Remove(b.elem)
+			delete(sh.buckets, key)
+		}
+	}
+}
+
+// evict removes the least recently used bucket.
+func (sh *shard) evict() {
+	if elem := sh.lru.Back(); elem != nil {
+		b := sh.lru.Remove(elem).(*bucket)
+		delete(sh.buckets, b.key)
+	}
+}
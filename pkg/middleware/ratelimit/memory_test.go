@@ -0,0 +1,141 @@
+package ratelimit_test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/middleware/ratelimit"
+)
+
+func TestMemoryStore_Take(t *testing.T) {
+	rate := ratelimit.Rate{Limit: 2, Period: 2 * time.Second}
+	start := time.Unix(1000, 0)
+
+	tests := []struct {
+		name    string
+		at      time.Duration
+		want    ratelimit.Result
+		wantErr bool
+	}{
+		{
+			name: "should allow the first request and take a token",
+			at:   0,
+			want: ratelimit.Result{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second},
+		},
+		{
+			name: "should allow the burst",
+			at:   0,
+			want: ratelimit.Result{Allowed: true, Limit: 2, Remaining: 0, Reset: 2 * time.Second},
+		},
+		{
+			name: "should deny when the bucket is empty",
+			at:   500 * time.Millisecond,
+			want: ratelimit.Result{
+				Limit:      2,
+				Reset:      1500 * time.Millisecond,
+				RetryAfter: 500 * time.Millisecond,
+			},
+		},
+		{
+			name: "should allow after the bucket is refilled with a token",
+			at:   time.Second,
+			want: ratelimit.Result{Allowed: true, Limit: 2, Remaining: 0, Reset: 2 * time.Second},
+		},
+		{
+			name: "should not overfill the bucket",
+			at:   time.Hour,
+			want: ratelimit.Result{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second},
+		},
+	}
+
+	ms := ratelimit.NewMemoryStore(ratelimit.MemoryConfig{})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ms.Take("key", rate, start.Add(tt.at))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("MemoryStore.Take() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMemoryStore_Eviction(t *testing.T) {
+	rate := ratelimit.Rate{Limit: 1, Period: time.Second}
+	start := time.Unix(1000, 0)
+
+	ms := ratelimit.NewMemoryStore(ratelimit.MemoryConfig{
+		Shards:        1,
+		MaxKeys:       3,
+		SweepInterval: time.Minute,
+	})
+
+	for i := 0; i < 5; i++ {
+		_, err := ms.Take(strconv.Itoa(i), rate, start.Add(time.Duration(i)*time.Millisecond))
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 3, ms.Len())
+
+	// the least recently used key was evicted, so it has the whole bucket again.
+	res, err := ms.Take("0", rate, start.Add(10*time.Millisecond))
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	// the recently used key is kept, the least recently used one is evicted instead.
+	for _, key := range []string{"0", "5"} {
+		_, err = ms.Take(key, rate, start.Add(20*time.Millisecond))
+		assert.NoError(t, err)
+	}
+
+	res, err = ms.Take("0", rate, start.Add(30*time.Millisecond))
+	assert.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 3, ms.Len())
+
+	// the refilled buckets are swept.
+	_, err = ms.Take("new", rate, start.Add(2*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, ms.Len())
+}
+
+func TestMemoryStore_Concurrent(t *testing.T) {
+	rate := ratelimit.Rate{Limit: 100, Period: time.Hour}
+	ms := ratelimit.NewMemoryStore(ratelimit.MemoryConfig{})
+	now := time.Now()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 20; j++ {
+				res, err := ms.Take("shared", rate, now)
+				assert.NoError(t, err)
+
+				if res.Allowed {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	assert.Equal(t, 100, allowed)
+}
@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/snobb/susanin/pkg/middleware"
+	"github.com/snobb/susanin/pkg/middleware/response"
+)
+
+// ErrLimitExceeded is the error returned to the client when the rate limit is exceeded.
+var ErrLimitExceeded = errors.New("rate limit exceeded")
+
+// Rate is the token bucket configuration. The bucket holds up to Limit tokens and is refilled
+// completely within the Period, so Limit is both the burst size and the number of requests
+// allowed per Period.
+type Rate struct {
+	Limit  int
+	Period time.Duration
+}
+
+// PerSecond creates a rate of n requests per second.
+func PerSecond(n int) Rate {
+	return Rate{Limit: n, Period: time.Second}
+}
+
+// PerMinute creates a rate of n requests per minute.
+func PerMinute(n int) Rate {
+	return Rate{Limit: n, Period: time.Minute}
+}
+
+// Result is the outcome of taking a token from the bucket.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // time until the bucket is full again
+	RetryAfter time.Duration // time until the next token is available if not allowed
+}
+
+// Store keeps the token buckets. It can be implemented by external backends shared between
+// several instances of the service.
+type Store interface {
+	// Take takes a token from the bucket identified by the key.
+	Take(key string, rate Rate, now time.Time) (Result, error)
+}
+
+// Config is the rate limiting middleware configuration.
+type Config struct {
+	// Rate is the token bucket rate.
+	Rate Rate
+
+	// Key identifies the client. Requests with an empty key are not limited. Zero value means
+	// ByIP.
+	Key KeyFunc
+
+	// Store keeps the token buckets. Zero value means a new MemoryStore with the default
+	// configuration.
+	Store Store
+
+	// Namespace separates the buckets of the middlewares sharing the Store, e.g. attached to
+	// different groups with the same Rate. The buckets of different rates are always separate.
+	Namespace string
+}
+
+// New creates a token bucket rate limiting middleware. The limit state is reported with the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers and the requests over the
+// limit are responded with HTTP 429 and Retry-After header. If the store fails the request is
+// let through.
+// Different limits for different groups of routes can be configured by attaching the
+// middleware with framework.Use to each group.
+// The function panics if the rate is invalid.
+func New(cfg Config) middleware.Middleware {
+	if cfg.Rate.Limit <= 0 || cfg.Rate.Period <= 0 {
+		panic("ratelimit: invalid rate")
+	}
+
+	if cfg.Key == nil {
+		cfg.Key = ByIP
+	}
+
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryStore(MemoryConfig{})
+	}
+
+	prefix := cfg.Namespace + "/" + strconv.Itoa(cfg.Rate.Limit) + "/" + cfg.Rate.Period.String() + "/"
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := cfg.Key(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			res, err := cfg.Store.Take(prefix+key, cfg.Rate, time.Now())
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", seconds(res.Reset))
+
+			if !res.Allowed {
+				h.Set("Retry-After", seconds(res.RetryAfter))
+				_ = response.New(w).Error(r.Context(), http.StatusTooManyRequests, ErrLimitExceeded)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// seconds formats the duration as a number of seconds rounded up.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,222 @@
+package ratelimit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/framework"
+	"github.com/snobb/susanin/pkg/middleware/proxy"
+	"github.com/snobb/susanin/pkg/middleware/ratelimit"
+	"github.com/snobb/susanin/test/helper"
+)
+
+func TestRateLimit_New(t *testing.T) {
+	type request struct {
+		remoteAddr    string
+		apiKey        string
+		wantCode      int
+		wantRemaining string
+	}
+
+	tests := map[string]struct {
+		cfg      ratelimit.Config
+		requests []request
+	}{
+		"should limit the requests by IP": {
+			cfg: ratelimit.Config{Rate: ratelimit.PerMinute(2)},
+			requests: []request{
+				{remoteAddr: "10.0.0.1:1234", wantCode: 200, wantRemaining: "1"},
+				{remoteAddr: "10.0.0.1:1235", wantCode: 200, wantRemaining: "0"},
+				{remoteAddr: "10.0.0.1:1236", wantCode: 429, wantRemaining: "0"},
+				{remoteAddr: "10.0.0.2:1234", wantCode: 200, wantRemaining: "1"},
+			},
+		},
+		"should limit the requests by API key and skip the requests without it": {
+			cfg: ratelimit.Config{
+				Rate: ratelimit.PerMinute(1),
+				Key:  ratelimit.ByHeader("X-Api-Key"),
+			},
+			requests: []request{
+				{remoteAddr: "10.0.0.1:1234", apiKey: "a", wantCode: 200, wantRemaining: "0"},
+				{remoteAddr: "10.0.0.2:1234", apiKey: "a", wantCode: 429, wantRemaining: "0"},
+				{remoteAddr: "10.0.0.2:1234", apiKey: "b", wantCode: 200, wantRemaining: "0"},
+				{remoteAddr: "10.0.0.2:1234", wantCode: 200},
+			},
+		},
+		"should limit the requests by API key and IP": {
+			cfg: ratelimit.Config{
+				Rate: ratelimit.PerMinute(1),
+				Key:  ratelimit.Compose(ratelimit.ByHeader("X-Api-Key"), ratelimit.ByIP),
+			},
+			requests: []request{
+				{remoteAddr: "10.0.0.1:1234", apiKey: "a", wantCode: 200, wantRemaining: "0"},
+				{remoteAddr: "10.0.0.2:1234", apiKey: "a", wantCode: 200, wantRemaining: "0"},
+				{remoteAddr: "10.0.0.2:1234", apiKey: "a", wantCode: 429, wantRemaining: "0"},
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := ratelimit.New(tt.cfg)(helper.HandlerFactory(200, "ok"))
+
+			for _, req := range tt.requests {
+				rec := httptest.NewRecorder()
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				r.RemoteAddr = req.remoteAddr
+				if req.apiKey != "" {
+					r.Header.Set("X-Api-Key", req.apiKey)
+				}
+
+				h.ServeHTTP(rec, r)
+
+				assert.Equal(t, req.wantCode, rec.Code)
+				assert.Equal(t, req.wantRemaining, rec.Header().Get("RateLimit-Remaining"))
+
+				if req.wantCode == 429 {
+					perToken := tt.cfg.Rate.Period / time.Duration(tt.cfg.Rate.Limit)
+					assert.Equal(t, strconv.Itoa(int(perToken.Seconds())), rec.Header().Get("Retry-After"))
+					assert.JSONEq(t,
+						`{"code":429,"error":"rate limit exceeded","message":"Too Many Requests"}`,
+						rec.Body.String())
+				}
+
+				if req.wantRemaining != "" {
+					assert.Equal(t, strconv.Itoa(tt.cfg.Rate.Limit), rec.Header().Get("RateLimit-Limit"))
+					assert.NotEmpty(t, rec.Header().Get("RateLimit-Reset"))
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimit_PerGroup(t *testing.T) {
+	fw := framework.New()
+	fw.Get("/open", helper.HandlerFactory(200, "open"))
+	fw.WithPrefix("/api", func() {
+		fw.Get("/users/:id", helper.HandlerFactory(200, "user"))
+	}, framework.Use(ratelimit.New(ratelimit.Config{
+		Rate: ratelimit.Rate{Limit: 2, Period: time.Hour},
+		Key:  ratelimit.ByRoute,
+	})))
+
+	codes := func(path string, n int) []int {
+		var res []int
+
+		for i := 0; i < n; i++ {
+			rec := httptest.NewRecorder()
+			fw.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			res = append(res, rec.Code)
+		}
+
+		return res
+	}
+
+	assert.Equal(t, []int{200, 200, 200}, codes("/open", 3))
+	assert.Equal(t, []int{200, 200}, codes("/api/users/1", 2))
+	// the route pattern is shared by all the users
+	assert.Equal(t, []int{429}, codes("/api/users/2", 1))
+}
+
+func TestRateLimit_ByRoute_Attach(t *testing.T) {
+	fw := framework.New()
+	fw.Get("/users/:id", helper.HandlerFactory(200, "user"))
+	fw.Attach(ratelimit.New(ratelimit.Config{
+		Rate: ratelimit.Rate{Limit: 1, Period: time.Hour},
+		Key:  ratelimit.ByRoute,
+	}))
+
+	// the requests are not limited per path without the route pattern
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		fw.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+		assert.Equal(t, 200, rec.Code)
+		assert.Empty(t, rec.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestRateLimit_SharedStore(t *testing.T) {
+	store := ratelimit.NewMemoryStore(ratelimit.MemoryConfig{})
+	limiter := func(limit int, namespace string) http.Handler {
+		return ratelimit.New(ratelimit.Config{
+			Rate:      ratelimit.Rate{Limit: limit, Period: time.Hour},
+			Store:     store,
+			Namespace: namespace,
+		})(helper.HandlerFactory(200, "ok"))
+	}
+
+	code := func(h http.Handler) int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rec.Code
+	}
+
+	tests := map[string]struct {
+		first  http.Handler
+		second http.Handler
+		want   int
+	}{
+		"should not share the buckets of different rates": {
+			first:  limiter(1, ""),
+			second: limiter(2, ""),
+			want:   200,
+		},
+		"should not share the buckets of different namespaces": {
+			first:  limiter(1, "a"),
+			second: limiter(1, "b"),
+			want:   200,
+		},
+		"should share the buckets of the same rate and namespace": {
+			first:  limiter(1, "c"),
+			second: limiter(1, "c"),
+			want:   429,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, 200, code(tt.first))
+			assert.Equal(t, tt.want, code(tt.second))
+		})
+	}
+}
+
+func TestRateLimit_InvalidRate(t *testing.T) {
+	assert.Panics(t, func() {
+		ratelimit.New(ratelimit.Config{})
+	})
+}
+
+func TestRateLimit_ByContext(t *testing.T) {
+	type subjectKey struct{}
+
+	key := ratelimit.ByContext(subjectKey{})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	assert.Equal(t, "", key(r))
+
+	r = r.WithContext(context.WithValue(r.Context(), subjectKey{}, "john"))
+	assert.Equal(t, "john", key(r))
+	// the pattern is not known outside of the routes
+	assert.Equal(t, "", ratelimit.ByRoute(r))
+}
+
+func TestRateLimit_ByIP(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7")
+	assert.Equal(t, "10.0.0.1", ratelimit.ByIP(r))
+
+	var key string
+	proxy.New(proxy.Config{TrustedProxies: []string{"10.0.0.0/8"}})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key = ratelimit.ByIP(r)
+		})).ServeHTTP(httptest.NewRecorder(), r)
+
+	assert.Equal(t, "203.0.113.7", key)
+}
@@ -0,0 +1,127 @@
+package secure
+
+import (
+	"strings"
+)
+
+// NonceSource is the placeholder source replaced with the per request 'nonce-...' source.
+const NonceSource = "'nonce'"
+
+// CSP is a Content-Security-Policy builder. The directives are rendered in the order they
+// were added.
+type CSP struct {
+	directives []directive
+}
+
+type directive struct {
+	name    string
+	sources []string
+}
+
+// NewCSP creates an empty policy.
+func NewCSP() *CSP {
+	return &CSP{}
+}
+
+// DefaultCSP creates a strict policy allowing the resources of the same origin and the
+// scripts with the request nonce.
+func DefaultCSP() *CSP {
+	return NewCSP().
+		Set("default-src", "'self'").
+		Set("script-src", "'self'", NonceSource).
+		Set("object-src", "'none'").
+		Set("base-uri", "'self'").
+		Set("frame-ancestors", "'none'")
+}
+
+// Set sets the directive sources replacing the existing ones. Directives without sources,
+// e.g. "upgrade-insecure-requests", are allowed.
+func (c *CSP) Set(name string, sources ...string) *CSP {
+	name = strings.ToLower(name)
+
+	for i := range c.directives {
+		if c.directives[i].name == name {
+			c.directives[i].sources = append([]string{}, sources...)
+			return c
+		}
+	}
+
+	c.directives = append(c.directives, directive{name: name, sources: append([]string{}, sources...)})
+	return c
+}
+
+// Add appends the sources to the directive.
+func (c *CSP) Add(name string, sources ...string) *CSP {
+	name = strings.ToLower(name)
+
+	for i := range c.directives {
+		if c.directives[i].name == name {
+			c.directives[i].sources = append(c.directives[i].sources, sources...)
+			return c
+		}
+	}
+
+	return c.Set(name, sources...)
+}
+
+// Remove removes the directive.
+func (c *CSP) Remove(name string) *CSP {
+	name = strings.ToLower(name)
+
+	for i := range c.directives {
+		if c.directives[i].name == name {
+			c.directives = append(c.directives[:i:i], c.directives[i+1:]...)
+			break
+		}
+	}
+
+	return c
+}
+
+// Clone returns a copy of the policy, e.g. to be modified for a group of routes.
+func (c *CSP) Clone() *CSP {
+	clone := &CSP{directives: make([]directive, len(c.directives))}
+	for i, d := range c.directives {
+		clone.directives[i] = directive{name: d.name, sources: append([]string{}, d.sources...)}
+	}
+
+	return clone
+}
+
+// UsesNonce reports if any of the directives has the NonceSource.
+func (c *CSP) UsesNonce() bool {
+	for _, d := range c.directives {
+		for _, src := range d.sources {
+			if src == NonceSource {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// String renders the policy with the nonce.
+func (c *CSP) String(nonce string) string {
+	var sb strings.Builder
+
+	for i, d := range c.directives {
+		if i > 0 {
+			sb.WriteString("; ")
+		}
+
+		sb.WriteString(d.name)
+
+		for _, src := range d.sources {
+			sb.WriteByte(' ')
+
+			if src == NonceSource {
+				sb.WriteString("'nonce-" + nonce + "'")
+			} else {
+				sb.WriteString(src)
+			}
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,179 @@
+package secure
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/snobb/susanin/pkg/middleware"
+	"github.com/snobb/susanin/pkg/middleware/proxy"
+)
+
+type nonceKey struct{}
+
+const (
+	// Disabled disables the header if used as the header value.
+	Disabled = "-"
+
+	// DefaultHSTSMaxAge is the default Strict-Transport-Security max-age.
+	DefaultHSTSMaxAge = 365 * 24 * time.Hour
+
+	// DefaultContentTypeOptions is the default X-Content-Type-Options value.
+	DefaultContentTypeOptions = "nosniff"
+
+	// DefaultFrameOptions is the default X-Frame-Options value.
+	DefaultFrameOptions = "DENY"
+
+	// DefaultReferrerPolicy is the default Referrer-Policy value.
+	DefaultReferrerPolicy = "strict-origin-when-cross-origin"
+)
+
+// Config is the security headers configuration. The zero value headers take the defaults and
+// the headers set to Disabled are not sent.
+type Config struct {
+	// HSTSMaxAge is the Strict-Transport-Security max-age. The header is sent over HTTPS only,
+	// as resolved by the proxy middleware if it is installed.
+	// Zero value means DefaultHSTSMaxAge and a negative value disables the header.
+	HSTSMaxAge time.Duration
+
+	// HSTSIncludeSubdomains adds the includeSubDomains directive.
+	HSTSIncludeSubdomains bool
+
+	// HSTSPreload adds the preload directive.
+	HSTSPreload bool
+
+	// ContentTypeOptions is the X-Content-Type-Options value. Zero value means
+	// DefaultContentTypeOptions.
+	ContentTypeOptions string
+
+	// FrameOptions is the X-Frame-Options value. Zero value means DefaultFrameOptions.
+	FrameOptions string
+
+	// ReferrerPolicy is the Referrer-Policy value. Zero value means DefaultReferrerPolicy.
+	ReferrerPolicy string
+
+	// PermissionsPolicy is the Permissions-Policy value, e.g. "camera=(), microphone=()".
+	// Zero value means the header is not sent.
+	PermissionsPolicy string
+
+	// CSP is the Content-Security-Policy. Nil means the header is not sent.
+	CSP *CSP
+
+	// CSPReportOnly sends the policy with the Content-Security-Policy-Report-Only header.
+	CSPReportOnly bool
+}
+
+// New creates a middleware setting the security headers. Every instance applies the complete
+// configuration, so the headers can be overridden per route or per WithPrefix group by
+// attaching another instance with framework.Use. The CSP nonce is generated once per request
+// and is shared by the nested instances.
+func New(cfg Config) middleware.Middleware {
+	var hsts string
+	if cfg.HSTSMaxAge >= 0 {
+		if cfg.HSTSMaxAge == 0 {
+			cfg.HSTSMaxAge = DefaultHSTSMaxAge
+		}
+
+		hsts = "max-age=" + strconv.FormatInt(int64(cfg.HSTSMaxAge/time.Second), 10)
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+
+		if cfg.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+
+	headers := []struct {
+		name, value, def string
+	}{
+		{"X-Content-Type-Options", cfg.ContentTypeOptions, DefaultContentTypeOptions},
+		{"X-Frame-Options", cfg.FrameOptions, DefaultFrameOptions},
+		{"Referrer-Policy", cfg.ReferrerPolicy, DefaultReferrerPolicy},
+		{"Permissions-Policy", cfg.PermissionsPolicy, Disabled},
+	}
+
+	static := make(map[string]string, len(headers))
+	for _, h := range headers {
+		if h.value == "" {
+			h.value = h.def
+		}
+
+		static[h.name] = h.value
+	}
+
+	cspHeader := "Content-Security-Policy"
+	if cfg.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+
+	var csp *CSP
+	if cfg.CSP != nil {
+		csp = cfg.CSP.Clone()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+
+			for name, value := range static {
+				if value == Disabled {
+					h.Del(name)
+				} else {
+					h.Set(name, value)
+				}
+			}
+
+			if hsts != "" && isHTTPS(r) {
+				h.Set("Strict-Transport-Security", hsts)
+			} else {
+				h.Del("Strict-Transport-Security")
+			}
+
+			h.Del("Content-Security-Policy")
+			h.Del("Content-Security-Policy-Report-Only")
+
+			if csp != nil {
+				nonce := Nonce(r.Context())
+				if nonce == "" && csp.UsesNonce() {
+					nonce = newNonce()
+					r = r.WithContext(context.WithValue(r.Context(), nonceKey{}, nonce))
+				}
+
+				h.Set(cspHeader, csp.String(nonce))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Nonce returns the CSP nonce of the request for the templates, e.g.
+// <script nonce="{{ .Nonce }}">. An empty string is returned if none of the applied policies
+// use the nonce.
+func Nonce(ctx context.Context) string {
+	nonce, _ := ctx.Value(nonceKey{}).(string)
+	return nonce
+}
+
+// isHTTPS reports if the client uses HTTPS. The scheme resolved by the proxy middleware is
+// used if it is installed.
+func isHTTPS(r *http.Request) bool {
+	if scheme, ok := proxy.Scheme(r.Context()); ok {
+		return scheme == "https"
+	}
+
+	return r.TLS != nil
+}
+
+func newNonce() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic("secure: cannot generate nonce: " + err.Error())
+	}
+
+	return base64.StdEncoding.EncodeToString(buf)
+}
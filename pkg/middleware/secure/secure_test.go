@@ -0,0 +1,175 @@
+package secure_test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/framework"
+	"github.com/snobb/susanin/pkg/middleware/proxy"
+	"github.com/snobb/susanin/pkg/middleware/secure"
+	"github.com/snobb/susanin/test/helper"
+)
+
+func TestCSP(t *testing.T) {
+	csp := secure.DefaultCSP()
+	assert.True(t, csp.UsesNonce())
+	assert.Equal(t, "default-src 'self'; script-src 'self' 'nonce-abc'; object-src 'none'; "+
+		"base-uri 'self'; frame-ancestors 'none'", csp.String("abc"))
+
+	clone := csp.Clone().
+		Add("script-src", "https://cdn.example.com").
+		Set("img-src", "'self'", "data:").
+		Remove("frame-ancestors").
+		Set("upgrade-insecure-requests")
+
+	assert.Equal(t, "default-src 'self'; script-src 'self' 'nonce-n' https://cdn.example.com; "+
+		"object-src 'none'; base-uri 'self'; img-src 'self' data:; upgrade-insecure-requests",
+		clone.String("n"))
+	assert.Equal(t, "default-src 'self'; script-src 'self' 'nonce-abc'; object-src 'none'; "+
+		"base-uri 'self'; frame-ancestors 'none'", csp.String("abc"), "original is unchanged")
+
+	assert.False(t, secure.NewCSP().Set("default-src", "'self'").UsesNonce())
+}
+
+func TestSecure(t *testing.T) {
+	cfg := secure.Config{
+		HSTSIncludeSubdomains: true,
+		PermissionsPolicy:     "camera=()",
+		CSP:                   secure.DefaultCSP(),
+	}
+
+	embed := cfg
+	embed.FrameOptions = "SAMEORIGIN"
+	embed.CSP = secure.DefaultCSP().Set("frame-ancestors", "'self'")
+
+	api := cfg
+	api.CSP = nil
+	api.PermissionsPolicy = secure.Disabled
+	api.HSTSMaxAge = -1
+
+	nonceHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(secure.Nonce(r.Context())))
+	})
+
+	fw := framework.New()
+	fw.Attach(secure.New(cfg))
+	fw.Get("/", nonceHandler)
+	fw.Get("/embed", nonceHandler, framework.Use(secure.New(embed)))
+	fw.WithPrefix("/api", func() {
+		fw.Get("/users", nonceHandler)
+	}, framework.Use(secure.New(api)))
+
+	nonceRe := regexp.MustCompile(`'nonce-([^']+)'`)
+
+	tests := map[string]struct {
+		path       string
+		tls        bool
+		wantHeader map[string]string
+		wantNonce  bool
+	}{
+		"should set the default headers": {
+			path: "/",
+			wantHeader: map[string]string{
+				"X-Content-Type-Options":    "nosniff",
+				"X-Frame-Options":           "DENY",
+				"Referrer-Policy":           "strict-origin-when-cross-origin",
+				"Permissions-Policy":        "camera=()",
+				"Strict-Transport-Security": "",
+			},
+			wantNonce: true,
+		},
+		"should set HSTS over HTTPS": {
+			path: "/",
+			tls:  true,
+			wantHeader: map[string]string{
+				"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+			},
+			wantNonce: true,
+		},
+		"should override the headers for the route": {
+			path: "/embed",
+			wantHeader: map[string]string{
+				"X-Frame-Options":    "SAMEORIGIN",
+				"Permissions-Policy": "camera=()",
+			},
+			wantNonce: true,
+		},
+		"should override the headers for the group": {
+			path: "/api/users",
+			tls:  true,
+			wantHeader: map[string]string{
+				"X-Frame-Options":           "DENY",
+				"Permissions-Policy":        "",
+				"Content-Security-Policy":   "",
+				"Strict-Transport-Security": "",
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+
+			rec := httptest.NewRecorder()
+			fw.ServeHTTP(rec, req)
+
+			assert.Equal(t, 200, rec.Code)
+
+			for name, value := range tt.wantHeader {
+				assert.Equal(t, value, rec.Header().Get(name), name)
+			}
+
+			if !tt.wantNonce {
+				return
+			}
+
+			csp := rec.Header().Get("Content-Security-Policy")
+			m := nonceRe.FindStringSubmatch(csp)
+			assert.Len(t, m, 2, csp)
+			assert.Equal(t, rec.Body.String(), m[1], "nonce must be shared with the handler")
+		})
+	}
+}
+
+func TestSecure_NoncePerRequest(t *testing.T) {
+	h := secure.New(secure.Config{CSP: secure.DefaultCSP(), CSPReportOnly: true})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(secure.Nonce(r.Context())))
+		}))
+
+	nonces := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Empty(t, rec.Header().Get("Content-Security-Policy"))
+		assert.Contains(t, rec.Header().Get("Content-Security-Policy-Report-Only"),
+			"'nonce-"+rec.Body.String()+"'")
+
+		nonces[rec.Body.String()] = true
+	}
+
+	assert.Len(t, nonces, 10)
+}
+
+func TestSecure_BehindProxy(t *testing.T) {
+	h := proxy.New(proxy.Config{TrustedProxies: []string{"10.0.0.0/8"}})(
+		secure.New(secure.Config{})(helper.HandlerFactory(200, "ok")))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	req.Header.Set("X-Forwarded-Proto", "https")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, "max-age=31536000", rec.Header().Get("Strict-Transport-Security"))
+}
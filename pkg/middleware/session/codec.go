@@ -0,0 +1,165 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidCookie is returned if the cookie value is malformed or its signature or
+	// encryption does not match any of the keys.
+	ErrInvalidCookie = errors.New("invalid session cookie")
+
+	// ErrExpiredCookie is returned if the cookie is older than the maximum age.
+	ErrExpiredCookie = errors.New("session cookie has expired")
+)
+
+// Key is a cookie key pair. Sign is the HMAC-SHA256 signing key. Encrypt is an optional
+// AES-128, AES-192 or AES-256 key; if set, the cookie payload is encrypted with AES-GCM.
+type Key struct {
+	Sign    []byte
+	Encrypt []byte
+}
+
+// Codec signs and optionally encrypts the cookie values. The first key is used to encode the
+// values and all the keys are tried to decode them, so the keys can be rotated by prepending
+// a new key and removing the oldest one once the cookies signed with it have expired.
+type Codec struct {
+	keys []codecKey
+}
+
+type codecKey struct {
+	sign []byte
+	aead cipher.AEAD
+}
+
+// NewCodec creates a new cookie codec.
+// The function panics if no keys are given, a signing key is empty or an encryption key has
+// an invalid size.
+func NewCodec(keys ...Key) *Codec {
+	if len(keys) == 0 {
+		panic("session: no keys")
+	}
+
+	c := &Codec{keys: make([]codecKey, len(keys))}
+	for i, key := range keys {
+		if len(key.Sign) == 0 {
+			panic("session: empty signing key")
+		}
+
+		c.keys[i].sign = key.Sign
+
+		if len(key.Encrypt) == 0 {
+			continue
+		}
+
+		block, err := aes.NewCipher(key.Encrypt)
+		if err != nil {
+			panic(fmt.Sprintf("session: invalid encryption key: %v", err))
+		}
+
+		if c.keys[i].aead, err = cipher.NewGCM(block); err != nil {
+			panic(fmt.Sprintf("session: invalid encryption key: %v", err))
+		}
+	}
+
+	return c
+}
+
+// Encode encodes the data for the cookie with the given name. The cookie name is
+// authenticated as well, so the value cannot be moved to another cookie.
+func (c *Codec) Encode(name string, data []byte, now time.Time) (string, error) {
+	key := c.keys[0]
+
+	payload := make([]byte, 8+len(data))
+	binary.BigEndian.PutUint64(payload, uint64(now.Unix()))
+	copy(payload[8:], data)
+
+	if key.aead != nil {
+		nonce := make([]byte, key.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+
+		payload = key.aead.Seal(nonce, nonce, payload, []byte(name))
+	}
+
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	return body + "." + base64.RawURLEncoding.EncodeToString(key.mac(name, body)), nil
+}
+
+// Decode verifies and decodes the cookie value. Values encoded more than maxAge ago are
+// rejected with ErrExpiredCookie. Zero maxAge disables the check.
+func (c *Codec) Decode(name, value string, maxAge time.Duration, now time.Time) ([]byte, error) {
+	data, _, err := c.decode(name, value, maxAge, now)
+	return data, err
+}
+
+// decode decodes the cookie value and reports if it was encoded with a rotated key.
+func (c *Codec) decode(name, value string, maxAge time.Duration,
+	now time.Time) ([]byte, bool, error) {
+	i := strings.LastIndexByte(value, '.')
+	if i < 0 {
+		return nil, false, ErrInvalidCookie
+	}
+
+	body := value[:i]
+
+	sig, err := base64.RawURLEncoding.DecodeString(value[i+1:])
+	if err != nil {
+		return nil, false, ErrInvalidCookie
+	}
+
+	for n, key := range c.keys {
+		if !hmac.Equal(key.mac(name, body), sig) {
+			continue
+		}
+
+		payload, err := base64.RawURLEncoding.DecodeString(body)
+		if err != nil {
+			return nil, false, ErrInvalidCookie
+		}
+
+		if key.aead != nil {
+			size := key.aead.NonceSize()
+			if len(payload) < size {
+				return nil, false, ErrInvalidCookie
+			}
+
+			payload, err = key.aead.Open(nil, payload[:size], payload[size:], []byte(name))
+			if err != nil {
+				return nil, false, ErrInvalidCookie
+			}
+		}
+
+		if len(payload) < 8 {
+			return nil, false, ErrInvalidCookie
+		}
+
+		issued := time.Unix(int64(binary.BigEndian.Uint64(payload)), 0)
+		if maxAge > 0 && now.Sub(issued) > maxAge {
+			return nil, false, ErrExpiredCookie
+		}
+
+		return payload[8:], n > 0, nil
+	}
+
+	return nil, false, ErrInvalidCookie
+}
+
+func (k codecKey) mac(name, body string) []byte {
+	mac := hmac.New(sha256.New, k.sign)
+	mac.Write([]byte(name))
+	mac.Write([]byte{'|'})
+	mac.Write([]byte(body))
+	return mac.Sum(nil)
+}
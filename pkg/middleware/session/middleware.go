@@ -0,0 +1,358 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/snobb/susanin/pkg/middleware"
+	"github.com/snobb/susanin/pkg/middleware/response"
+)
+
+type stateKey struct{}
+
+const (
+	// DefaultName is the default session cookie name.
+	DefaultName = "session"
+
+	// DefaultMaxAge is the default session lifetime.
+	DefaultMaxAge = 24 * time.Hour
+
+	// maxCookieSize is the maximum cookie value size supported by the browsers.
+	maxCookieSize = 4096
+)
+
+var (
+	// ErrNoSession is returned by Session if the session middleware is not installed.
+	ErrNoSession = errors.New("session middleware is not installed")
+
+	// ErrCookieTooLarge is returned if the encoded session does not fit in a cookie.
+	ErrCookieTooLarge = errors.New("session cookie is too large")
+)
+
+// Config is the session middleware configuration.
+type Config struct {
+	// Keys are the cookie keys. The first key is used to sign the cookies and the rest are
+	// accepted to allow the key rotation.
+	Keys []Key
+
+	// Store keeps the session data on the server side. If nil, the session data is kept in
+	// the cookie itself.
+	Store SessionStore
+
+	// Name is the cookie name. Zero value means DefaultName.
+	Name string
+
+	// MaxAge is the session lifetime since the last modification. Zero value means
+	// DefaultMaxAge.
+	MaxAge time.Duration
+
+	// Path is the cookie path. Zero value means "/".
+	Path string
+
+	// Domain is the cookie domain.
+	Domain string
+
+	// Secure restricts the cookie to HTTPS.
+	Secure bool
+
+	// SameSite is the cookie SameSite attribute. Zero value means http.SameSiteLaxMode.
+	SameSite http.SameSite
+
+	// Now returns the current time. Zero value means time.Now.
+	Now func() time.Time
+}
+
+// record is the cookie payload if there is no server side store.
+type record struct {
+	ID     string                 `json:"id"`
+	Values map[string]interface{} `json:"v"`
+}
+
+type manager struct {
+	cfg   Config
+	codec *Codec
+}
+
+// New creates a session middleware. The session is loaded on the first Session call and is
+// saved before the response header is written only if it has been modified, so the handlers
+// that do not use the session do not pay for it.
+// The function panics if the keys are invalid.
+func New(cfg Config) middleware.Middleware {
+	m := &manager{codec: NewCodec(cfg.Keys...)}
+
+	if cfg.Name == "" {
+		cfg.Name = DefaultName
+	}
+
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = DefaultMaxAge
+	}
+
+	if cfg.Path == "" {
+		cfg.Path = "/"
+	}
+
+	if cfg.SameSite == 0 {
+		cfg.SameSite = http.SameSiteLaxMode
+	}
+
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	m.cfg = cfg
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			st := &state{m: m, r: r}
+			sw := &sessionWriter{ResponseWriter: w, st: st}
+
+			next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), stateKey{}, st)))
+
+			if !sw.written {
+				if err := st.save(w); err != nil {
+					_ = response.New(w).Error(r.Context(), http.StatusInternalServerError, err)
+				}
+			}
+		})
+	}
+}
+
+// Session returns the request session loading it on the first call. A new session is started
+// if the request has no valid session cookie.
+func Session(ctx context.Context) (*Data, error) {
+	st, ok := ctx.Value(stateKey{}).(*state)
+	if !ok {
+		return nil, ErrNoSession
+	}
+
+	return st.session()
+}
+
+// load loads the session from the cookie and the store.
+func (m *manager) load(r *http.Request) (*Data, error) {
+	c, err := r.Cookie(m.cfg.Name)
+	if err != nil {
+		return newSession()
+	}
+
+	now := m.cfg.Now()
+
+	data, rotated, err := m.codec.decode(m.cfg.Name, c.Value, m.cfg.MaxAge, now)
+	if err != nil {
+		return newSession()
+	}
+
+	if m.cfg.Store == nil {
+		var rec record
+		if err := json.Unmarshal(data, &rec); err != nil || rec.ID == "" {
+			return newSession()
+		}
+
+		return m.restore(rec.ID, rec.Values, rotated), nil
+	}
+
+	id := string(data)
+
+	data, err = m.cfg.Store.Load(id, now)
+	if errors.Is(err, ErrNotFound) {
+		return newSession()
+	} else if err != nil {
+		return nil, err
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return newSession()
+	}
+
+	return m.restore(id, values, rotated), nil
+}
+
+// restore creates a loaded session. The sessions signed with a rotated key are re-signed.
+func (m *manager) restore(id string, values map[string]interface{}, rotated bool) *Data {
+	if values == nil {
+		values = make(map[string]interface{})
+	}
+
+	return &Data{id: id, values: values, changed: rotated}
+}
+
+// save stores the modified session and sets the cookie.
+func (m *manager) save(w http.ResponseWriter, s *Data) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.destroyed {
+		if m.cfg.Store != nil && !s.isNew {
+			if err := m.deleteSessions(s.id, s.oldID); err != nil {
+				return err
+			}
+		}
+
+		http.SetCookie(w, m.cookie("", -1))
+		return nil
+	}
+
+	if !s.changed {
+		return nil
+	}
+
+	now := m.cfg.Now()
+
+	var (
+		payload []byte
+		err     error
+	)
+
+	if m.cfg.Store == nil {
+		payload, err = json.Marshal(record{ID: s.id, Values: s.values})
+		if err != nil {
+			return err
+		}
+	} else {
+		data, err := json.Marshal(s.values)
+		if err != nil {
+			return err
+		}
+
+		if err := m.deleteSessions(s.oldID); err != nil {
+			return err
+		}
+
+		if err := m.cfg.Store.Save(s.id, data, now.Add(m.cfg.MaxAge)); err != nil {
+			return err
+		}
+
+		payload = []byte(s.id)
+	}
+
+	value, err := m.codec.Encode(m.cfg.Name, payload, now)
+	if err != nil {
+		return err
+	}
+
+	if len(value) > maxCookieSize {
+		return ErrCookieTooLarge
+	}
+
+	http.SetCookie(w, m.cookie(value, int(m.cfg.MaxAge/time.Second)))
+	return nil
+}
+
+func (m *manager) deleteSessions(ids ...string) error {
+	for _, id := range ids {
+		if id == "" {
+			continue
+		}
+
+		if err := m.cfg.Store.Delete(id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *manager) cookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     m.cfg.Name,
+		Value:    value,
+		Path:     m.cfg.Path,
+		Domain:   m.cfg.Domain,
+		MaxAge:   maxAge,
+		Secure:   m.cfg.Secure,
+		HttpOnly: true,
+		SameSite: m.cfg.SameSite,
+	}
+}
+
+// state is the per request session state.
+type state struct {
+	m *manager
+	r *http.Request
+
+	mu    sync.Mutex
+	sess  *Data
+	err   error
+	saved bool
+}
+
+func (st *state) session() (*Data, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.sess == nil && st.err == nil {
+		st.sess, st.err = st.m.load(st.r)
+	}
+
+	return st.sess, st.err
+}
+
+// save saves the session once if it has been loaded.
+func (st *state) save(w http.ResponseWriter) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.saved || st.sess == nil {
+		return nil
+	}
+
+	st.saved = true
+	return st.m.save(w, st.sess)
+}
+
+// sessionWriter saves the session before the response header is written. If the session
+// cannot be saved the response is replaced with HTTP 500.
+type sessionWriter struct {
+	http.ResponseWriter
+	st      *state
+	written bool
+	failed  bool
+}
+
+// WriteHeader implements the http.ResponseWriter interface.
+func (sw *sessionWriter) WriteHeader(status int) {
+	if sw.written {
+		return
+	}
+
+	sw.written = true
+
+	if err := sw.st.save(sw.ResponseWriter); err != nil {
+		sw.failed = true
+		_ = response.New(sw.ResponseWriter).Error(sw.st.r.Context(),
+			http.StatusInternalServerError, err)
+		return
+	}
+
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements the http.ResponseWriter interface.
+func (sw *sessionWriter) Write(data []byte) (int, error) {
+	if !sw.written {
+		sw.WriteHeader(http.StatusOK)
+	}
+
+	if sw.failed {
+		return len(data), nil
+	}
+
+	return sw.ResponseWriter.Write(data)
+}
+
+// Flush implements the http.Flusher interface.
+func (sw *sessionWriter) Flush() {
+	if !sw.written {
+		sw.WriteHeader(http.StatusOK)
+	}
+
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok && !sw.failed {
+		f.Flush()
+	}
+}
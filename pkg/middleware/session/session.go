@@ -0,0 +1,164 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+)
+
+// flashKey is the session key the flash messages are kept under.
+const flashKey = "_flash"
+
+// Data is a user session. The values are serialised as JSON, so the numbers are decoded as
+// float64 and the structures as maps. The methods are safe for concurrent use.
+type Data struct {
+	mu        sync.Mutex
+	id        string
+	oldID     string
+	values    map[string]interface{}
+	isNew     bool
+	changed   bool
+	destroyed bool
+}
+
+func newSession() (*Data, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Data{id: id, values: make(map[string]interface{}), isNew: true}, nil
+}
+
+// ID returns the session ID.
+func (s *Data) ID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.id
+}
+
+// IsNew reports if the session has been created by the current request.
+func (s *Data) IsNew() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.isNew
+}
+
+// Get returns the value stored under the key or nil.
+func (s *Data) Get(key string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.values[key]
+}
+
+// GetString returns the string value stored under the key or an empty string.
+func (s *Data) GetString(key string) string {
+	v, _ := s.Get(key).(string)
+	return v
+}
+
+// Set stores the value under the key.
+func (s *Data) Set(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.values[key] = value
+	s.changed = true
+}
+
+// Delete removes the value stored under the key.
+func (s *Data) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.values[key]; ok {
+		delete(s.values, key)
+		s.changed = true
+	}
+}
+
+// Clear removes all the session values.
+func (s *Data) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.values) > 0 {
+		s.values = make(map[string]interface{})
+		s.changed = true
+	}
+}
+
+// AddFlash adds a flash message that is kept until it is read with Flashes.
+func (s *Data) AddFlash(msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	flashes, _ := s.values[flashKey].([]interface{})
+	s.values[flashKey] = append(flashes, msg)
+	s.changed = true
+}
+
+// Flashes returns and removes the flash messages.
+func (s *Data) Flashes() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	flashes, ok := s.values[flashKey].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	delete(s.values, flashKey)
+	s.changed = true
+
+	res := make([]string, 0, len(flashes))
+	for _, flash := range flashes {
+		if msg, ok := flash.(string); ok {
+			res = append(res, msg)
+		}
+	}
+
+	return res
+}
+
+// Regenerate assigns a new ID to the session keeping the values. The old session is removed
+// from the store. It should be called on privilege changes, e.g. login, to prevent session
+// fixation.
+func (s *Data) Regenerate() error {
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isNew && s.oldID == "" {
+		s.oldID = s.id
+	}
+
+	s.id = id
+	s.changed = true
+	return nil
+}
+
+// Destroy removes the session from the store and expires the cookie.
+func (s *Data) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.values = make(map[string]interface{})
+	s.destroyed = true
+}
+
+func newID() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
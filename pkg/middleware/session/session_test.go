@@ -0,0 +1,287 @@
+package session_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/middleware/session"
+)
+
+var (
+	now     = time.Unix(1600000000, 0)
+	oldKey  = session.Key{Sign: []byte("old-signing-key")}
+	signKey = session.Key{Sign: []byte("signing-key")}
+	encKey  = session.Key{Sign: []byte("signing-key"), Encrypt: []byte("0123456789abcdef")}
+)
+
+func TestCodec(t *testing.T) {
+	tests := map[string]struct {
+		encode  *session.Codec
+		decode  *session.Codec
+		name    string
+		tamper  func(string) string
+		age     time.Duration
+		wantErr error
+	}{
+		"should decode signed value": {
+			encode: session.NewCodec(signKey),
+			decode: session.NewCodec(signKey),
+		},
+		"should decode encrypted value": {
+			encode: session.NewCodec(encKey),
+			decode: session.NewCodec(encKey),
+		},
+		"should decode value signed with a rotated key": {
+			encode: session.NewCodec(oldKey),
+			decode: session.NewCodec(signKey, oldKey),
+		},
+		"should reject value signed with an unknown key": {
+			encode:  session.NewCodec(oldKey),
+			decode:  session.NewCodec(signKey),
+			wantErr: session.ErrInvalidCookie,
+		},
+		"should reject value moved to another cookie": {
+			encode:  session.NewCodec(signKey),
+			decode:  session.NewCodec(signKey),
+			name:    "other",
+			wantErr: session.ErrInvalidCookie,
+		},
+		"should reject tampered value": {
+			encode:  session.NewCodec(encKey),
+			decode:  session.NewCodec(encKey),
+			tamper:  func(v string) string { return "A" + v[1:] },
+			wantErr: session.ErrInvalidCookie,
+		},
+		"should reject malformed value": {
+			encode:  session.NewCodec(signKey),
+			decode:  session.NewCodec(signKey),
+			tamper:  func(v string) string { return strings.Replace(v, ".", "", 1) },
+			wantErr: session.ErrInvalidCookie,
+		},
+		"should reject expired value": {
+			encode:  session.NewCodec(signKey),
+			decode:  session.NewCodec(signKey),
+			age:     2 * time.Hour,
+			wantErr: session.ErrExpiredCookie,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			value, err := tt.encode.Encode("session", []byte("data"), now)
+			assert.NoError(t, err)
+			assert.NotContains(t, value, "data")
+
+			if tt.tamper != nil {
+				value = tt.tamper(value)
+			}
+
+			cookie := "session"
+			if tt.name != "" {
+				cookie = tt.name
+			}
+
+			data, err := tt.decode.Decode(cookie, value, time.Hour, now.Add(tt.age))
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), "got error %v, want %v", err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("data"), data)
+		})
+	}
+}
+
+func TestNewCodec_Panics(t *testing.T) {
+	assert.Panics(t, func() { session.NewCodec() })
+	assert.Panics(t, func() { session.NewCodec(session.Key{}) })
+	assert.Panics(t, func() { session.NewCodec(session.Key{Sign: []byte("k"), Encrypt: []byte("k")}) })
+}
+
+// client keeps the session cookie between the requests.
+type client struct {
+	t       *testing.T
+	handler http.Handler
+	cookie  *http.Cookie
+}
+
+func (c *client) do(path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if c.cookie != nil {
+		req.AddCookie(c.cookie)
+	}
+
+	rec := httptest.NewRecorder()
+	c.handler.ServeHTTP(rec, req)
+
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			c.cookie = nil
+		} else {
+			c.cookie = cookie
+		}
+	}
+
+	return rec
+}
+
+func handler(t *testing.T) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		s, err := session.Session(r.Context())
+		assert.NoError(t, err)
+		assert.NoError(t, s.Regenerate())
+		s.Set("user", "john")
+		s.AddFlash("welcome")
+		_, _ = w.Write([]byte(s.ID()))
+	})
+
+	mux.HandleFunc("/whoami", func(w http.ResponseWriter, r *http.Request) {
+		s, err := session.Session(r.Context())
+		assert.NoError(t, err)
+		_, _ = w.Write([]byte(s.GetString("user") + " " + strings.Join(s.Flashes(), ",")))
+	})
+
+	mux.HandleFunc("/logout", func(w http.ResponseWriter, r *http.Request) {
+		s, err := session.Session(r.Context())
+		assert.NoError(t, err)
+		s.Destroy()
+	})
+
+	mux.HandleFunc("/static", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("static"))
+	})
+
+	return mux
+}
+
+func TestSession(t *testing.T) {
+	tests := map[string]struct {
+		store func(t *testing.T) session.SessionStore
+		key   session.Key
+	}{
+		"should keep the session in a signed cookie": {
+			key: signKey,
+		},
+		"should keep the session in an encrypted cookie": {
+			key: encKey,
+		},
+		"should keep the session in the memory store": {
+			store: func(t *testing.T) session.SessionStore { return session.NewMemoryStore() },
+			key:   signKey,
+		},
+		"should keep the session in the file store": {
+			store: func(t *testing.T) session.SessionStore {
+				fs, err := session.NewFileStore(t.TempDir())
+				assert.NoError(t, err)
+				return fs
+			},
+			key: signKey,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := session.Config{Keys: []session.Key{tt.key}, Secure: true}
+			if tt.store != nil {
+				cfg.Store = tt.store(t)
+			}
+
+			c := &client{t: t, handler: session.New(cfg)(handler(t))}
+
+			rec := c.do("/static")
+			assert.Empty(t, rec.Result().Cookies(), "unused session must not set a cookie")
+
+			rec = c.do("/login")
+			assert.NotNil(t, c.cookie)
+			assert.True(t, c.cookie.HttpOnly)
+			assert.True(t, c.cookie.Secure)
+			assert.Equal(t, http.SameSiteLaxMode, c.cookie.SameSite)
+			assert.NotContains(t, c.cookie.Value, "john")
+			id := rec.Body.String()
+
+			assert.Equal(t, "john welcome", c.do("/whoami").Body.String())
+			assert.Equal(t, "john ", c.do("/whoami").Body.String(), "flashes are read once")
+
+			// regenerating the ID of the established session replaces it
+			old := c.cookie
+			rec = c.do("/login")
+			assert.NotEqual(t, id, rec.Body.String())
+
+			if tt.store != nil {
+				c.cookie, old = old, c.cookie
+				assert.Equal(t, " ", c.do("/whoami").Body.String(), "old session must be removed")
+				c.cookie = old
+			}
+
+			rec = c.do("/logout")
+			assert.Nil(t, c.cookie)
+			assert.Equal(t, " ", c.do("/whoami").Body.String())
+		})
+	}
+}
+
+func TestSession_KeyRotation(t *testing.T) {
+	c := &client{t: t, handler: session.New(session.Config{Keys: []session.Key{oldKey}})(handler(t))}
+	c.do("/login")
+	c.do("/whoami") // consume the flashes, so the session is not modified anymore
+	old := c.cookie.Value
+
+	c.handler = session.New(session.Config{Keys: []session.Key{signKey, oldKey}})(handler(t))
+	assert.Equal(t, "john ", c.do("/whoami").Body.String())
+	assert.NotEqual(t, old, c.cookie.Value, "cookie must be re-signed with the new key")
+
+	c.handler = session.New(session.Config{Keys: []session.Key{signKey}})(handler(t))
+	assert.Equal(t, "john ", c.do("/whoami").Body.String())
+}
+
+func TestSession_Expiry(t *testing.T) {
+	clock := now
+	cfg := session.Config{
+		Keys:   []session.Key{signKey},
+		MaxAge: time.Hour,
+		Now:    func() time.Time { return clock },
+	}
+
+	c := &client{t: t, handler: session.New(cfg)(handler(t))}
+	c.do("/login")
+	assert.Equal(t, 3600, c.cookie.MaxAge)
+
+	// reading the flashes modifies the session, so the expiration is extended
+	clock = clock.Add(30 * time.Minute)
+	assert.Equal(t, "john welcome", c.do("/whoami").Body.String())
+
+	clock = clock.Add(time.Hour + time.Second)
+	assert.Equal(t, " ", c.do("/whoami").Body.String())
+}
+
+type failingStore struct {
+	session.SessionStore
+}
+
+func (failingStore) Save(id string, data []byte, expires time.Time) error {
+	return errors.New("disk is full")
+}
+
+func TestSession_SaveError(t *testing.T) {
+	cfg := session.Config{Keys: []session.Key{signKey}, Store: failingStore{session.NewMemoryStore()}}
+	c := &client{t: t, handler: session.New(cfg)(handler(t))}
+
+	rec := c.do("/login")
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "disk is full")
+	assert.Nil(t, c.cookie)
+}
+
+func TestSession_NoMiddleware(t *testing.T) {
+	_, err := session.Session(httptest.NewRequest(http.MethodGet, "/", nil).Context())
+	assert.True(t, errors.Is(err, session.ErrNoSession))
+}
@@ -0,0 +1,222 @@
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSweepInterval is the default interval between sweeps of the expired sessions in the
+// memory store.
+const DefaultSweepInterval = time.Minute
+
+// ErrNotFound is returned by a SessionStore if the session does not exist or has expired.
+var ErrNotFound = errors.New("session not found")
+
+// SessionStore keeps the session data on the server side. The cookie carries the signed
+// session ID only.
+type SessionStore interface {
+	// Load returns the session data or ErrNotFound if there is no session with the ID or it
+	// has expired by now.
+	Load(id string, now time.Time) ([]byte, error)
+
+	// Save stores the session data until the expiration time.
+	Save(id string, data []byte, expires time.Time) error
+
+	// Delete removes the session. Deleting a missing session is not an error.
+	Delete(id string) error
+}
+
+// MemoryStore is an in-memory SessionStore. The sessions are lost on restart and are not
+// shared between the processes.
+type MemoryStore struct {
+	mu        sync.Mutex
+	sessions  map[string]memoryEntry
+	lastSweep time.Time
+}
+
+type memoryEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+// NewMemoryStore creates a new in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]memoryEntry)}
+}
+
+// Load implements the SessionStore interface.
+func (ms *MemoryStore) Load(id string, now time.Time) ([]byte, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if now.Sub(ms.lastSweep) >= DefaultSweepInterval {
+		ms.sweep(now)
+	}
+
+	entry, ok := ms.sessions[id]
+	if !ok || !now.Before(entry.expires) {
+		delete(ms.sessions, id)
+		return nil, ErrNotFound
+	}
+
+	return append([]byte{}, entry.data...), nil
+}
+
+// Save implements the SessionStore interface.
+func (ms *MemoryStore) Save(id string, data []byte, expires time.Time) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.sessions[id] = memoryEntry{data: append([]byte{}, data...), expires: expires}
+	return nil
+}
+
+// Delete implements the SessionStore interface.
+func (ms *MemoryStore) Delete(id string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	delete(ms.sessions, id)
+	return nil
+}
+
+// Len returns the number of the sessions kept in the store including the expired ones that
+// have not been swept yet.
+func (ms *MemoryStore) Len() int {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return len(ms.sessions)
+}
+
+func (ms *MemoryStore) sweep(now time.Time) {
+	for id, entry := range ms.sessions {
+		if !now.Before(entry.expires) {
+			delete(ms.sessions, id)
+		}
+	}
+
+	ms.lastSweep = now
+}
+
+// fileExt is the extension of the session files.
+const fileExt = ".session"
+
+// FileStore is a SessionStore keeping every session in a separate file in a directory. The
+// file names are derived from the session ID hashes, so the IDs are not exposed on disk.
+// The expired files are removed on access or by Sweep.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a new file store. The directory is created if it does not exist.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &FileStore{dir: dir}, nil
+}
+
+// Load implements the SessionStore interface.
+func (fs *FileStore) Load(id string, now time.Time) ([]byte, error) {
+	path := fs.path(id)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 8 || !now.Before(fileExpires(data)) {
+		_ = os.Remove(path)
+		return nil, ErrNotFound
+	}
+
+	return data[8:], nil
+}
+
+// Save implements the SessionStore interface. The file is replaced atomically.
+func (fs *FileStore) Save(id string, data []byte, expires time.Time) error {
+	tmp, err := os.CreateTemp(fs.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = os.Remove(tmp.Name()) }() // no-op once renamed
+
+	var hdr [8]byte
+	binary.BigEndian.PutUint64(hdr[:], uint64(expires.UnixNano()))
+
+	if _, err := tmp.Write(hdr[:]); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), fs.path(id))
+}
+
+// Delete implements the SessionStore interface.
+func (fs *FileStore) Delete(id string) error {
+	if err := os.Remove(fs.path(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Sweep removes the expired session files and returns the number of the removed files.
+func (fs *FileStore) Sweep(now time.Time) (int, error) {
+	entries, err := os.ReadDir(fs.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), fileExt) {
+			continue
+		}
+
+		path := filepath.Join(fs.dir, entry.Name())
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		if len(data) < 8 || !now.Before(fileExpires(data)) {
+			if os.Remove(path) == nil {
+				removed++
+			}
+		}
+	}
+
+	return removed, nil
+}
+
+func (fs *FileStore) path(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return filepath.Join(fs.dir, hex.EncodeToString(sum[:])+fileExt)
+}
+
+func fileExpires(data []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(data)))
+}
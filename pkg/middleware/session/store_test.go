@@ -0,0 +1,84 @@
+package session_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/middleware/session"
+)
+
+func TestStores(t *testing.T) {
+	tests := map[string]func(t *testing.T) session.SessionStore{
+		"should store sessions in memory": func(t *testing.T) session.SessionStore {
+			return session.NewMemoryStore()
+		},
+		"should store sessions in files": func(t *testing.T) session.SessionStore {
+			fs, err := session.NewFileStore(t.TempDir())
+			assert.NoError(t, err)
+			return fs
+		},
+	}
+
+	for name, newStore := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+
+			_, err := store.Load("missing", now)
+			assert.True(t, errors.Is(err, session.ErrNotFound))
+
+			assert.NoError(t, store.Save("a", []byte("one"), now.Add(time.Hour)))
+			assert.NoError(t, store.Save("b", []byte("two"), now.Add(time.Minute)))
+			assert.NoError(t, store.Save("a", []byte("three"), now.Add(time.Hour)))
+
+			data, err := store.Load("a", now)
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("three"), data)
+
+			_, err = store.Load("b", now.Add(time.Minute))
+			assert.True(t, errors.Is(err, session.ErrNotFound), "expired session")
+
+			assert.NoError(t, store.Delete("a"))
+			assert.NoError(t, store.Delete("a"))
+
+			_, err = store.Load("a", now)
+			assert.True(t, errors.Is(err, session.ErrNotFound))
+		})
+	}
+}
+
+func TestMemoryStore_Sweep(t *testing.T) {
+	ms := session.NewMemoryStore()
+	assert.NoError(t, ms.Save("a", nil, now.Add(time.Second)))
+	assert.NoError(t, ms.Save("b", nil, now.Add(time.Hour)))
+	assert.Equal(t, 2, ms.Len())
+
+	_, err := ms.Load("b", now.Add(session.DefaultSweepInterval))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, ms.Len())
+}
+
+func TestFileStore_Sweep(t *testing.T) {
+	dir := t.TempDir()
+
+	fs, err := session.NewFileStore(dir)
+	assert.NoError(t, err)
+
+	assert.NoError(t, fs.Save("a", []byte("one"), now.Add(time.Second)))
+	assert.NoError(t, fs.Save("b", []byte("two"), now.Add(time.Hour)))
+
+	removed, err := fs.Sweep(now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	data, err := fs.Load("b", now)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("two"), data)
+}
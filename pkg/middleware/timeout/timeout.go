@@ -0,0 +1,201 @@
+package timeout
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/snobb/susanin/pkg/middleware"
+	"github.com/snobb/susanin/pkg/middleware/response"
+)
+
+// DefaultTimeout is the default request timeout.
+const DefaultTimeout = 5 * time.Second
+
+// ErrTimeout is the error returned to the client when the request times out.
+var ErrTimeout = errors.New("request timed out")
+
+// Config is the timeout middleware configuration.
+type Config struct {
+	// Timeout is the request timeout. Zero value means DefaultTimeout.
+	Timeout time.Duration
+
+	// Header is the name of the request header the client can use to request a different
+	// timeout, e.g. X-Request-Timeout. The value is either a Go duration (e.g. "1.5s") or a
+	// number of seconds. Empty value disables the header.
+	Header string
+
+	// MaxTimeout caps the timeout requested by the client. Zero value means Timeout, so the
+	// clients can only shorten the timeout.
+	MaxTimeout time.Duration
+
+	// Code is the HTTP status code responded on timeout. Zero value means HTTP 503.
+	Code int
+}
+
+// New creates a middleware that sets the request context deadline and responds with a JSON
+// error if the handler does not respond in time. Any writes made by the handler after the
+// timeout fail with http.ErrHandlerTimeout.
+// Nested timeouts can only shorten the deadline, so for different timeouts per group of routes
+// the middleware should be attached to each group with framework.Use rather than globally.
+func New(cfg Config) middleware.Middleware {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+
+	if cfg.MaxTimeout <= 0 {
+		cfg.MaxTimeout = cfg.Timeout
+	}
+
+	if cfg.Code == 0 {
+		cfg.Code = http.StatusServiceUnavailable
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), cfg.timeout(r))
+			defer cancel()
+
+			tw := &timeoutWriter{w: w, h: make(http.Header)}
+			done := make(chan struct{})
+			panicChan := make(chan interface{}, 1)
+
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicChan <- p
+					}
+				}()
+
+				next.ServeHTTP(tw, r.WithContext(ctx))
+				close(done)
+			}()
+
+			select {
+			case p := <-panicChan:
+				panic(p)
+
+			case <-done:
+
+			case <-ctx.Done():
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+
+				tw.timedOut = true
+				if !tw.wroteHeader {
+					_ = response.New(w).Error(ctx, cfg.Code, ErrTimeout)
+				}
+			}
+		})
+	}
+}
+
+// timeout returns the timeout for the request honouring the client requested timeout.
+func (cfg *Config) timeout(r *http.Request) time.Duration {
+	if cfg.Header == "" {
+		return cfg.Timeout
+	}
+
+	d, ok := parseTimeout(r.Header.Get(cfg.Header))
+	if !ok {
+		return cfg.Timeout
+	}
+
+	if d > cfg.MaxTimeout {
+		return cfg.MaxTimeout
+	}
+
+	return d
+}
+
+// parseTimeout parses a Go duration or a number of seconds.
+func parseTimeout(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		secs, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		d = time.Duration(secs * float64(time.Second))
+	}
+
+	return d, d > 0
+}
+
+// timeoutWriter guards the response writer from being written after the timeout. The handler
+// headers are kept separately and copied to the response when the header is written.
+type timeoutWriter struct {
+	w           http.ResponseWriter
+	h           http.Header
+	mu          sync.Mutex
+	timedOut    bool
+	wroteHeader bool
+}
+
+// Header returns the handler response headers.
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.h
+}
+
+// WriteHeader writes the response header unless the request has timed out.
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return
+	}
+
+	tw.writeHeader(code)
+}
+
+// Write writes the data unless the request has timed out.
+func (tw *timeoutWriter) Write(data []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	tw.writeHeader(http.StatusOK)
+	return tw.w.Write(data)
+}
+
+// Flush implements the http.Flusher interface.
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return
+	}
+
+	tw.writeHeader(http.StatusOK)
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (tw *timeoutWriter) writeHeader(code int) {
+	if tw.wroteHeader {
+		return
+	}
+
+	tw.wroteHeader = true
+
+	dst := tw.w.Header()
+	for k, vv := range tw.h {
+		dst[k] = vv
+	}
+
+	tw.w.WriteHeader(code)
+}
@@ -0,0 +1,128 @@
+package timeout_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/middleware/timeout"
+)
+
+func TestTimeout_New(t *testing.T) {
+	tests := map[string]struct {
+		cfg      timeout.Config
+		header   string
+		delay    time.Duration
+		wantCode int
+		wantBody string
+	}{
+		"should respond normally if the handler is fast enough": {
+			cfg:      timeout.Config{Timeout: time.Second},
+			wantCode: 200,
+			wantBody: "ok",
+		},
+		"should respond with 503 if the handler is too slow": {
+			cfg:      timeout.Config{Timeout: 20 * time.Millisecond},
+			delay:    time.Second,
+			wantCode: 503,
+			wantBody: `{"code":503,"error":"request timed out","message":"Service Unavailable"}`,
+		},
+		"should respond with the configured code": {
+			cfg:      timeout.Config{Timeout: 20 * time.Millisecond, Code: 504},
+			delay:    time.Second,
+			wantCode: 504,
+			wantBody: `{"code":504,"error":"request timed out","message":"Gateway Timeout"}`,
+		},
+		"should honour the shorter client timeout": {
+			cfg:      timeout.Config{Timeout: time.Second, Header: "X-Request-Timeout"},
+			header:   "20ms",
+			delay:    500 * time.Millisecond,
+			wantCode: 503,
+			wantBody: `{"code":503,"error":"request timed out","message":"Service Unavailable"}`,
+		},
+		"should cap the client timeout by the server policy": {
+			cfg: timeout.Config{
+				Timeout:    time.Second,
+				MaxTimeout: 20 * time.Millisecond,
+				Header:     "X-Request-Timeout",
+			},
+			header:   "10",
+			delay:    500 * time.Millisecond,
+			wantCode: 503,
+			wantBody: `{"code":503,"error":"request timed out","message":"Service Unavailable"}`,
+		},
+		"should ignore invalid client timeout": {
+			cfg:      timeout.Config{Timeout: time.Second, Header: "X-Request-Timeout"},
+			header:   "soon",
+			delay:    20 * time.Millisecond,
+			wantCode: 200,
+			wantBody: "ok",
+		},
+	}
+
+	for name, tt := range tests {
+		tt := tt // the handler may outlive the iteration
+		t.Run(name, func(t *testing.T) {
+			h := timeout.New(tt.cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, ok := r.Context().Deadline()
+				assert.True(t, ok)
+
+				select {
+				case <-time.After(tt.delay):
+				case <-r.Context().Done():
+					return
+				}
+
+				w.Header().Set("X-Handler", "true")
+				_, _ = w.Write([]byte("ok"))
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Request-Timeout", tt.header)
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, tt.wantBody, strings.TrimSpace(rec.Body.String()))
+		})
+	}
+}
+
+func TestTimeout_WriteAfterTimeout(t *testing.T) {
+	written := make(chan error, 1)
+
+	h := timeout.New(timeout.Config{Timeout: 10 * time.Millisecond})(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			time.Sleep(10 * time.Millisecond)
+
+			w.Header().Set("X-Late", "true")
+			_, err := w.Write([]byte("late"))
+			written <- err
+		}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.ErrHandlerTimeout, <-written)
+	assert.Equal(t, 503, rec.Code)
+	assert.Empty(t, rec.Header().Get("X-Late"))
+	assert.NotContains(t, rec.Body.String(), "late")
+}
+
+func TestTimeout_Panic(t *testing.T) {
+	h := timeout.New(timeout.Config{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("spanner")
+	}))
+
+	assert.PanicsWithValue(t, "spanner", func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
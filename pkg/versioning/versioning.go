@@ -0,0 +1,231 @@
+package versioning
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snobb/susanin/pkg/framework"
+	"github.com/snobb/susanin/pkg/middleware/response"
+)
+
+type versionKey struct{}
+
+var (
+	// ErrInvalidVersion is returned if the requested version cannot be parsed.
+	ErrInvalidVersion = errors.New("invalid API version")
+
+	// ErrUnsupportedVersion is returned if there is no handler for the requested version or
+	// any lower one.
+	ErrUnsupportedVersion = errors.New("unsupported API version")
+)
+
+// Strategy extracts the requested version from the request. It returns false if the request
+// does not specify the version in the way the strategy handles.
+type Strategy func(r *http.Request) (string, bool)
+
+// Path takes the version from the route variable, e.g. Path("version") for the routes
+// registered as "/api/:version/items".
+func Path(name string) Strategy {
+	return func(r *http.Request) (string, bool) {
+		values, _ := framework.GetValues(r.Context())
+		v, ok := values[name]
+		return v, ok
+	}
+}
+
+// Header takes the version from the request header, e.g. "X-Api-Version: 2".
+func Header(name string) Strategy {
+	return func(r *http.Request) (string, bool) {
+		v := r.Header.Get(name)
+		return v, v != ""
+	}
+}
+
+// MediaType takes the version from the Accept header media types of the vendor, e.g.
+// MediaType("application/vnd.ours") handles "application/vnd.ours.v2+json" as well as
+// "application/vnd.ours+json; version=2".
+func MediaType(vendor string) Strategy {
+	vendor = strings.ToLower(vendor)
+
+	return func(r *http.Request) (string, bool) {
+		for _, accept := range r.Header.Values("Accept") {
+			for _, item := range strings.Split(accept, ",") {
+				mediaType, params, err := mime.ParseMediaType(item)
+				if err != nil || !strings.HasPrefix(mediaType, vendor) {
+					continue
+				}
+
+				rest := mediaType[len(vendor):]
+				if i := strings.IndexByte(rest, '+'); i >= 0 {
+					rest = rest[:i]
+				}
+
+				if strings.HasPrefix(rest, ".") {
+					return rest[1:], true
+				}
+
+				if v, ok := params["version"]; ok && rest == "" {
+					return v, true
+				}
+			}
+		}
+
+		return "", false
+	}
+}
+
+// Deprecation describes a retired version.
+type Deprecation struct {
+	// Date is the deprecation date sent in the Deprecation header.
+	Date time.Time
+
+	// Sunset is the date the version stops being served sent in the Sunset header.
+	Sunset time.Time
+
+	// Link is the URL of the migration guide sent in the Link header.
+	Link string
+}
+
+// Config is the versioning configuration.
+type Config struct {
+	// Strategies are tried in order until one of them finds the requested version.
+	Strategies []Strategy
+
+	// Default is the version used if the request does not specify any. Zero value means the
+	// latest version of the handler.
+	Default int
+
+	// Deprecations are the retired versions.
+	Deprecations map[int]Deprecation
+}
+
+// Versioner routes the requests to the version specific handlers.
+type Versioner struct {
+	cfg Config
+}
+
+// New creates a new versioner.
+// The function panics if there are no strategies.
+func New(cfg Config) *Versioner {
+	if len(cfg.Strategies) == 0 {
+		panic("versioning: no strategies")
+	}
+
+	// the configuration is copied, so the caller can reuse it
+	cfg.Strategies = append([]Strategy(nil), cfg.Strategies...)
+
+	deprecations := make(map[int]Deprecation, len(cfg.Deprecations))
+	for version, dep := range cfg.Deprecations {
+		deprecations[version] = dep
+	}
+
+	cfg.Deprecations = deprecations
+
+	return &Versioner{cfg: cfg}
+}
+
+// Resolve returns the version requested by the client or zero if the request does not specify
+// any. The versions are integers optionally prefixed with "v", e.g. "2" or "v2".
+func (v *Versioner) Resolve(r *http.Request) (int, error) {
+	for _, strategy := range v.cfg.Strategies {
+		s, ok := strategy(r)
+		if !ok {
+			continue
+		}
+
+		n, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(s), "v"))
+		if err != nil || n <= 0 {
+			return 0, ErrInvalidVersion
+		}
+
+		return n, nil
+	}
+
+	return v.cfg.Default, nil
+}
+
+// Handle creates a handler serving the requests with the handler of the requested version or
+// the nearest lower one if there is no handler for the version itself, so a new version only
+// needs the handlers that have changed. The served version is available with Version. The
+// requests for unparsable versions are responded with HTTP 400 and the requests for versions
+// lower than all the handlers with HTTP 404.
+// The function panics if there are no handlers or a version is not positive.
+func (v *Versioner) Handle(handlers map[int]http.Handler) http.Handler {
+	if len(handlers) == 0 {
+		panic("versioning: no handlers")
+	}
+
+	versions := make([]int, 0, len(handlers))
+	for version := range handlers {
+		if version <= 0 {
+			panic("versioning: versions must be positive")
+		}
+
+		versions = append(versions, version)
+	}
+
+	sort.Ints(versions)
+
+	// the handlers are copied, so the caller can reuse the map
+	targets := make([]http.Handler, len(versions))
+	for i, version := range versions {
+		targets[i] = handlers[version]
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested, err := v.Resolve(r)
+		if err != nil {
+			_ = response.New(w).Error(r.Context(), http.StatusBadRequest, err)
+			return
+		}
+
+		if requested == 0 {
+			requested = versions[len(versions)-1]
+		}
+
+		// the nearest version lower or equal to the requested one
+		i := sort.SearchInts(versions, requested+1) - 1
+		if i < 0 {
+			_ = response.New(w).Error(r.Context(), http.StatusNotFound, ErrUnsupportedVersion)
+			return
+		}
+
+		served := versions[i]
+		v.deprecate(w.Header(), served)
+
+		ctx := context.WithValue(r.Context(), versionKey{}, served)
+		targets[i].ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// deprecate sets the Deprecation, Sunset and Link headers of the retired version.
+func (v *Versioner) deprecate(h http.Header, version int) {
+	dep, ok := v.cfg.Deprecations[version]
+	if !ok {
+		return
+	}
+
+	if !dep.Date.IsZero() {
+		h.Set("Deprecation", "@"+strconv.FormatInt(dep.Date.Unix(), 10))
+	}
+
+	if !dep.Sunset.IsZero() {
+		h.Set("Sunset", dep.Sunset.UTC().Format(http.TimeFormat))
+	}
+
+	if dep.Link != "" {
+		h.Add("Link", "<"+dep.Link+`>; rel="deprecation"`)
+	}
+}
+
+// Version returns the API version served by the handler.
+func Version(ctx context.Context) (int, bool) {
+	version, ok := ctx.Value(versionKey{}).(int)
+	return version, ok
+}
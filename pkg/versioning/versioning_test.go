@@ -0,0 +1,195 @@
+package versioning_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/snobb/susanin/pkg/framework"
+	"github.com/snobb/susanin/pkg/versioning"
+)
+
+func handler(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version, ok := versioning.Version(r.Context())
+		if !ok {
+			http.Error(w, "no version", http.StatusInternalServerError)
+			return
+		}
+
+		_, _ = w.Write([]byte(name + " v" + strconv.Itoa(version)))
+	})
+}
+
+func TestVersioner(t *testing.T) {
+	deprecated := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	api := versioning.New(versioning.Config{
+		Strategies: []versioning.Strategy{
+			versioning.Path("version"),
+			versioning.Header("X-Api-Version"),
+			versioning.MediaType("application/vnd.ours"),
+		},
+		Deprecations: map[int]versioning.Deprecation{
+			1: {Date: deprecated, Sunset: sunset, Link: "https://example.com/migrate"},
+		},
+	})
+
+	items := api.Handle(map[int]http.Handler{
+		1: handler("items"),
+		2: handler("items"),
+		4: handler("items"),
+	})
+
+	users := api.Handle(map[int]http.Handler{2: handler("users")})
+
+	fw := framework.New()
+	fw.Get("/api/:version/items", items)
+	fw.Get("/items", items)
+	fw.Get("/users", users)
+
+	tests := map[string]struct {
+		path       string
+		header     map[string]string
+		wantCode   int
+		wantBody   string
+		wantSunset string
+	}{
+		"should resolve version from path": {
+			path:     "/api/v2/items",
+			wantCode: 200,
+			wantBody: "items v2",
+		},
+		"should resolve version from header": {
+			path:     "/items",
+			header:   map[string]string{"X-Api-Version": "2"},
+			wantCode: 200,
+			wantBody: "items v2",
+		},
+		"should resolve version from vendor media type": {
+			path:     "/items",
+			header:   map[string]string{"Accept": "text/html, application/vnd.ours.v2+json"},
+			wantCode: 200,
+			wantBody: "items v2",
+		},
+		"should resolve version from media type parameter": {
+			path:     "/items",
+			header:   map[string]string{"Accept": "application/vnd.ours+json; version=2"},
+			wantCode: 200,
+			wantBody: "items v2",
+		},
+		"should prefer path over header": {
+			path:     "/api/v4/items",
+			header:   map[string]string{"X-Api-Version": "1"},
+			wantCode: 200,
+			wantBody: "items v4",
+		},
+		"should fall back to the nearest lower version": {
+			path:     "/api/v3/items",
+			wantCode: 200,
+			wantBody: "items v2",
+		},
+		"should fall back to the latest version for future versions": {
+			path:     "/api/v9/items",
+			wantCode: 200,
+			wantBody: "items v4",
+		},
+		"should serve the latest version by default": {
+			path:     "/items",
+			wantCode: 200,
+			wantBody: "items v4",
+		},
+		"should set deprecation headers for retired versions": {
+			path:       "/api/v1/items",
+			wantCode:   200,
+			wantBody:   "items v1",
+			wantSunset: "Wed, 01 Jan 2025 00:00:00 GMT",
+		},
+		"should reject versions lower than all handlers": {
+			path:     "/users",
+			header:   map[string]string{"X-Api-Version": "1"},
+			wantCode: 404,
+		},
+		"should reject invalid versions": {
+			path:     "/api/latest/items",
+			wantCode: 400,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+
+			rec := httptest.NewRecorder()
+			fw.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, rec.Body.String())
+			}
+
+			assert.Equal(t, tt.wantSunset, rec.Header().Get("Sunset"))
+			if tt.wantSunset != "" {
+				assert.Equal(t, "@1704067200", rec.Header().Get("Deprecation"))
+				assert.Equal(t, `<https://example.com/migrate>; rel="deprecation"`,
+					rec.Header().Get("Link"))
+			} else {
+				assert.Empty(t, rec.Header().Get("Deprecation"))
+			}
+		})
+	}
+}
+
+func TestVersioner_Default(t *testing.T) {
+	api := versioning.New(versioning.Config{
+		Strategies: []versioning.Strategy{versioning.Header("X-Api-Version")},
+		Default:    1,
+	})
+
+	rec := httptest.NewRecorder()
+	api.Handle(map[int]http.Handler{1: handler("a"), 2: handler("a")}).
+		ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, "a v1", rec.Body.String())
+}
+
+func TestVersioner_HandlersCopied(t *testing.T) {
+	api := versioning.New(versioning.Config{
+		Strategies: []versioning.Strategy{versioning.Header("X-Api-Version")},
+	})
+
+	handlers := map[int]http.Handler{1: handler("a"), 2: handler("b")}
+	h := api.Handle(handlers)
+
+	// the changes of the map after the handler is created do not affect it
+	delete(handlers, 2)
+	handlers[1] = nil
+
+	for version, want := range map[string]string{"1": "a v1", "2": "b v2", "3": "b v2"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Api-Version", version)
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, want, rec.Body.String())
+	}
+}
+
+func TestVersioner_Invalid(t *testing.T) {
+	assert.Panics(t, func() { versioning.New(versioning.Config{}) })
+
+	api := versioning.New(versioning.Config{
+		Strategies: []versioning.Strategy{versioning.Header("X-Api-Version")},
+	})
+
+	assert.Panics(t, func() { api.Handle(nil) })
+	assert.Panics(t, func() { api.Handle(map[int]http.Handler{0: handler("a")}) })
+}